@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"github.com/raiderops/capernicus/inventory"
+	"sort"
+	"strings"
+	"time"
+)
+
+// auditOutput is an audit record as clerk audit prints it in json and yaml, with the same
+// stability promise as hostOutput
+type auditOutput struct {
+	Id          string                 `json:"id" yaml:"id"`
+	Time        time.Time              `json:"time" yaml:"time"`
+	User        string                 `json:"user" yaml:"user"`
+	Hostname    string                 `json:"hostname" yaml:"hostname"`
+	Command     string                 `json:"command" yaml:"command"`
+	Operation   string                 `json:"operation" yaml:"operation"`
+	Environment string                 `json:"environment" yaml:"environment"`
+	Hosts       []string               `json:"hosts" yaml:"hosts"`
+	Groups      []string               `json:"groups" yaml:"groups"`
+	Result      string                 `json:"result" yaml:"result"`
+	Error       string                 `json:"error" yaml:"error"`
+	Before      []auditDocumentsOutput `json:"before" yaml:"before"`
+	After       []auditDocumentsOutput `json:"after" yaml:"after"`
+}
+
+// auditDocumentsOutput holds the documents of a datastore an audited operation was about, the
+// environment is null unless the operation changed it
+type auditDocumentsOutput struct {
+	Datastore   string             `json:"datastore" yaml:"datastore"`
+	Environment *environmentOutput `json:"environment" yaml:"environment"`
+	Hosts       []hostOutput       `json:"hosts" yaml:"hosts"`
+	Groups      []groupOutput      `json:"groups" yaml:"groups"`
+}
+
+type environmentOutput struct {
+	Name   string   `json:"name" yaml:"name"`
+	Groups []string `json:"groups" yaml:"groups"`
+}
+
+func newAuditOutput(record inventory.AuditRecord) auditOutput {
+	return auditOutput{
+		Id:          record.Id,
+		Time:        record.Time,
+		User:        record.User,
+		Hostname:    record.Hostname,
+		Command:     record.Command,
+		Operation:   record.Operation,
+		Environment: record.Environment,
+		Hosts:       nonNilNames(record.Hosts),
+		Groups:      nonNilNames(record.Groups),
+		Result:      record.Result,
+		Error:       record.Error,
+		Before:      newAuditDocumentsOutput(record.Before),
+		After:       newAuditDocumentsOutput(record.After),
+	}
+}
+
+func newAuditDocumentsOutput(snapshots []inventory.AuditDocuments) []auditDocumentsOutput {
+	result := make([]auditDocumentsOutput, 0, len(snapshots))
+	for _, docs := range snapshots {
+		out := auditDocumentsOutput{
+			Datastore: docs.Datastore,
+			Hosts:     make([]hostOutput, 0, len(docs.Hosts)),
+			Groups:    make([]groupOutput, 0, len(docs.Groups)),
+		}
+		if docs.Environment != nil {
+			groupNames := []string{}
+			for name := range docs.Environment.Groups {
+				groupNames = append(groupNames, name)
+			}
+			sort.Strings(groupNames)
+			out.Environment = &environmentOutput{Name: docs.Environment.Name, Groups: groupNames}
+		}
+		for _, host := range docs.Hosts {
+			out.Hosts = append(out.Hosts, newHostOutput(host, docs.Datastore))
+		}
+		for _, group := range docs.Groups {
+			out.Groups = append(out.Groups, newGroupOutput(group, docs.Datastore))
+		}
+		result = append(result, out)
+	}
+	return result
+}
+
+// returns a list of names, an empty list for none so that scripts always get an array
+func nonNilNames(names []string) []string {
+	if names == nil {
+		return []string{}
+	}
+	return names
+}
+
+// parses the value of a time range flag: a date, an RFC 3339 time or a duration before now.
+// The empty value is the zero time, which leaves that end of the range open.
+func parseTime(name, value string) time.Time {
+	if value == "" {
+		return time.Time{}
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t
+	}
+	if d, err := time.ParseDuration(value); err == nil && d >= 0 {
+		return time.Now().Add(-d)
+	}
+
+	exit(EXITINVALID, "ERROR", "The value: "+value+" of -"+name+" is not a date, an RFC 3339 time or a duration.")
+	return time.Time{}
+}
+
+// prints the audit records of the environment that match the filter, oldest first
+func (c *clerk) listAuditRecords(filter inventory.AuditFilter, format string) {
+	records, err := c.inv.AuditRecords(filter)
+	if err != nil {
+		fail(err)
+	}
+
+	if format == JSONOUTPUT || format == YAMLOUTPUT {
+		result := make([]auditOutput, 0, len(records))
+		for _, record := range records {
+			result = append(result, newAuditOutput(record))
+		}
+		printOutput(format, result)
+		return
+	}
+
+	for _, record := range records {
+		// the hosts the operation was about, or its groups for a group operation
+		subjects := record.Hosts
+		if len(subjects) == 0 {
+			subjects = record.Groups
+		}
+		result := record.Result
+		if record.Error != "" {
+			result += ": " + record.Error
+		}
+
+		fmt.Println(record.Time.Local().Format("2006-01-02 15:04:05") + "  " + record.User + "@" + record.Hostname + "  " +
+			record.Operation + " " + strings.Join(subjects, ",") + "  [" + result + "]")
+		fmt.Println("    " + record.Command)
+	}
+}
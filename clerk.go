@@ -1,2622 +1,717 @@
 package main
 
 import (
-	"bufio"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"gopkg.in/mgo.v2"
-	"gopkg.in/mgo.v2/bson"
+	"github.com/raiderops/capernicus/inventory"
+	"io"
 	"os"
-	"strconv"
+	"sort"
 	"strings"
-	"time"
 )
 
-// define static config constants
-const MONGOIP string = "127.0.0.1"
-
-// Top-Level Flag Definitions
-var addhost = flag.Bool("addHost", false, "Use this flag to add a host to the provisioner database")
-var deletehost = flag.Bool("deleteHost", false, "Use this flag to delete a host from the provisioner database")
-var attachhost = flag.Bool("attachHost", false, "Use this flag to attach a host to a group")
-var detachhost = flag.Bool("detachHost", false, "Use this flag to remove a host from a group")
-var movehost = flag.Bool("moveHost", false, "Use this flag to move a host from one Ansible group to another")
-var addgroup = flag.Bool("addGroup", false, "Use this flag to add a group to the provisioner database")
-var deletegroup = flag.Bool("deleteGroup", false, "Use this flag to delete a group from the provisioner database")
-var clonehost = flag.Bool("cloneHost", false, "Use this flag to clone a host")
-var addenv = flag.Bool("addEnvironment", false, "Use this flag to delete a group from the provisioner database")
-var push = flag.Bool("push", false, "Use this flag to push a host to the custodian database")
-var pull = flag.Bool("pull", false, "Use this flag to pull a host from the custodian database")
-var listgroups = flag.Bool("listGroups", false, "Use this flag to list the groups from the specified database")
-var listhostopts = flag.Bool("hostOptions", false, "Use this flag to list the hosts from the specified database")
-var listgroupopts = flag.Bool("groupOptions", false, "Use this flag to list the groups from the specified database")
-var hostdetails = flag.Bool("hostDetails", false, "Use this flag to display the host details for a given host")
-var groupdetails = flag.Bool("groupDetails", false, "Use this flag to display the group details for a given group")
-var addregion = flag.Bool("addRegion", false, "Use this flag to add a new geographical or logical region")
-
-// Sub-Flag Definitions
-var fqdn = flag.String("fqdn", "EMPTY", "Fully Qualified Domain Name of the host")
-var groups = flag.String("groups", "EMPTY", "The Name of an Ansible Group")
-var hosts = flag.String("hosts", "EMPTY", "A comma delimited list of hostnames or a single hostname")
-var group = flag.String("group", "EMPTY", "The Name of an Ansible Group")
-var template = flag.String("template", "EMPTY", "The Name of the template to use to create clone")
-var clone = flag.String("clone", "EMPTY", "The name of the clone to be added to the provisioner database")
-var environment = flag.String("environment", "EMPTY", "The name of an Ansible Compute Environment")
-var description = flag.String("description", "EMPTY", "A Short Description of the Ansible Group")
-var togroup = flag.String("to-group", "EMPTY", "A valid Ansible group")
-var fromgroup = flag.String("from-group", "EMPTY", "A valid Ansible group")
-var ostype = flag.String("osType", "EMPTY", "Operating System Type (e.g, CentOS|RedHat)")
-var osversion = flag.String("osVersion", "EMPTY", "Operating System Version (e.g, 7.0)")
-var machinearch = flag.String("archType", "EMPTY", "Machine Architecture Type (e.g, x86_64)")
-var datastore = flag.String("datastore", "EMPTY", "Datastore name to run against (e.g, provisioner)")
-var region = flag.String("region", "EMPTY", "Geographical region to run against (Atlanta)")
-
-// Type Definitions
-type AnsibleGroups struct {
-	Members     map[string][]string
-	Description string
-	Environment string
-	Name        string
-}
-
-type AnsibleHostMeta struct {
-	VarMap map[string]string
-}
-
-type AnsibleHost struct {
-	Fqdn        string
-	Groups      map[string]bool
-	Environment string
-}
-
-type AnsibleEnvironment struct {
-	Name   string
-	Prefix string
-	Groups map[string]bool
-}
+// The environment every command runs against, see selectEnvironment
+var ENV string
 
-type AnsibleHostVars struct {
-	Name   string
-	VarMap map[string]string
-}
-
-type InventoryFile struct {
-	Path        string
-	Environment string
-}
+// The loaded configuration of this installation
+var CONFIG *inventory.Config
 
 func main() {
+	args := os.Args[1:]
 
-	if len(os.Args) < 2 {
-		listInventory()
-		os.Exit(0)
-
-	}
-
-	// Ensure that argument list constains the required parameter or do nothing
-	if os.Args[1] == "--list" {
-		listInventory()
+	// the version needs neither a configuration nor a database
+	if len(args) > 0 && args[0] == "version" {
+		printVersion()
 		os.Exit(0)
 	}
 
-	if os.Args[1] == "--host" {
-		if len(os.Args) != 3 {
-			fmt.Println("\n[ ERROR ] --> This switch requires a single parameter that is a hostname.\n")
-			os.Exit(1)
-		}
-
-		listHostVars()
-		os.Exit(0)
-	}
-
-	if os.Args[1] == "--add-host" {
-		// get Database from stdin
-		dbReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter the database to which you wish to add the host: ")
-		dbName, _ := dbReader.ReadString('\n')
-		dBase := strings.Trim(dbName, "\n")
-
-		// get host FQDN from stdin
-		fqdnReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter FQDN of host you wish to add: ")
-		fqdName, _ := fqdnReader.ReadString('\n')
-		fName := strings.Trim(fqdName, "\n")
-
-		// get host OS Type from stdin
-		osTypeReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter The Type of Operating System of the host you wish to add (RedHat|CentOS): ")
-		oType, _ := osTypeReader.ReadString('\n')
-		osType := strings.Trim(oType, "\n")
-
-		// get host OS Version from stdin
-		osVersionReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter The Version of Operating System of the host you wish to add (e.g, 7.0): ")
-		oVersion, _ := osVersionReader.ReadString('\n')
-		osVersion := strings.Trim(oVersion, "\n")
-
-		// get host Machine Architecture from stdin
-		machineArchReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter The  Machine Architecture of the host you wish to add (e.g, x86_64): ")
-		mArch, _ := machineArchReader.ReadString('\n')
-		machArch := strings.Trim(mArch, "\n")
-
-		// validate environment
-		if !envExists(ENV, dBase) {
-			fmt.Println("\n[ FAILED ] --> The environment: " + ENV + " does not exist in the database.\n")
-			os.Exit(1)
-		}
-
-		// validate host
-		if hostExists(fName, ENV, dBase) {
-			fmt.Println("\n[ FAILED ] --> The Host: " + fName + " already exists in Environment: " + ENV + ".\n")
-			os.Exit(1)
-		}
-
-		// ensure that the data store has been provided
-		if dBase != "provisioner" && dBase != "custodian" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a valid value for the datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		groupsMap := make(map[string]bool)
-
-		aHost := AnsibleHost{Fqdn: fName, Groups: groupsMap, Environment: ENV}
-		// adding host to datastore -- should never have a host added to both datastores at the same time.
-		addHost(aHost, dBase)
-
-		fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in " + dBase + "...............\n")
-		updateInventoryFile(ENV, dBase)
-		fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + "\n")
-
+	// Ansible runs the inventory with --list, or --host and a hostname, or with no arguments at all
+	if len(args) == 0 || isSwitch(args[0], "list") || isSwitch(args[0], "host") {
+		runAnsible(args)
 		os.Exit(0)
 	}
 
-	if os.Args[1] == "--list-groups" {
-
-		// validate Environment
-		if !envExists(ENV, "provisioner") {
-			fmt.Println("\n[ FAILED ] --> The environment: " + ENV + " does not exist in the database.\n")
-			os.Exit(1)
-		}
-
-		// list groups and their description that exist in the supplied environment
-		listGroups(ENV, "provisioner")
-		os.Exit(0)
-	}
-
-	if os.Args[1] == "--display-host" {
-
-		// ensure that the data store has been provided
-		if *datastore == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		// get hostname (fqdn) from stdin
-		fqdnReader := bufio.NewReader(os.Stdin)
-		fmt.Print("Enter FQDN of host: ")
-		hostName, _ := fqdnReader.ReadString('\n')
-		hName := strings.Trim(hostName, "\n")
-
-		// validate host
-		if !hostExists(hName, ENV, *datastore) {
-			fmt.Println("\n[ FAILED ] --> The Host: " + hName + " does not exist in Environment: " + ENV + ".\n")
-			os.Exit(1)
-		}
-
-		displayHost(hName, ENV, *datastore)
-		os.Exit(0)
-	}
-
-	if os.Args[1] == "--group-options" {
-		// Get list of groups
-		listGroupOptions(ENV, "provisioner")
-		os.Exit(0)
-	}
-
-	if os.Args[1] == "--host-options" {
-		//Get list of hosts
-		listHostOptions(ENV, *datastore)
-		os.Exit(0)
-	}
-
-	if os.Args[1] == "--add-group" {
-		// get Database from stdin
-		dbReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter the database to which you wish to add the host: ")
-		dbName, _ := dbReader.ReadString('\n')
-		dBase := strings.Trim(dbName, "\n")
-
-		// get group name from stdin
-		groupNameReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter Name of group to add : ")
-		groupName, _ := groupNameReader.ReadString('\n')
-		gName := strings.Trim(groupName, "\n")
-
-		// get group description from stdin
-		groupReader := bufio.NewReader(os.Stdin)
-		fmt.Print("Enter short group description: ")
-		groupDescription, _ := groupReader.ReadString('\n')
-		gDesc := strings.Trim(groupDescription, "\n")
-
-		// setup the group members map with empty members slice
-		groupMembers := map[string][]string{gName: make([]string, 0)}
-		aGroup := AnsibleGroups{Members: groupMembers, Description: gDesc, Environment: ENV, Name: gName}
-
-		if dBase == "all" {
-			if !envExists(ENV, "provisioner") || !envExists(ENV, "custodian") {
-				fmt.Println("\n[ ERROR ] --> The Environment: " + ENV + " does not exist in all databases.\n")
-				fmt.Println("\n[ FATAL ERROR ] --> Please Ensure that your environments are set up correctly....Exiting.\n")
-				os.Exit(1)
-
-			}
-			if groupExists(gName, ENV, "provisioner") && groupExists(gName, ENV, "custodian") {
-				fmt.Println("\n[ ERROR ] --> Group: " + gName + " already exists in all databases...skipping add.\n")
-				os.Exit(1)
-			}
-
-			if groupExists(gName, ENV, "provisioner") {
-				fmt.Println("\n[ INFO ] --> group: " + gName + " already exists in provisioner...skipping add.\n")
-			} else {
-				// Add the group to the requested environment in provisioner datastore
-				addGroup(aGroup, "provisioner")
-				fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in provisioner...............\n")
-				// Update Inventory File
-				updateInventoryFile(ENV, "provisioner")
-				fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in provisioner\n")
-			}
-
-			if groupExists(gName, ENV, "custodian") {
-				fmt.Println("\n[ INFO ] --> group: " + gName + " already exists in custodian...skipping add.\n")
-			} else {
-				// Add the group to the requested environment in custodian datastore
-				addGroup(aGroup, "custodian")
-				fmt.Println("\n[ INFO] --> Updating Inventory file for Environment: " + ENV + " in custodian...............\n")
-				// Update Inventory File
-				updateInventoryFile(ENV, "custodian")
-				fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in custodian.\n")
-			}
-
-			os.Exit(0)
-
-		} else {
-			if !envExists(ENV, dBase) {
-				fmt.Println("\n[ ERROR ] --> The Environment: " + ENV + " does not exist in the database: " + dBase + ".\n")
-				os.Exit(1)
-			}
-
-			if groupExists(gName, ENV, dBase) {
-				fmt.Println("\n[ WARNING ] --> The group: " + gName + " already exists in the datastore: " + dBase + "...skipping add.\n")
-				os.Exit(0)
-			} else {
-				// Add the group the requested environment in the specified datastore
-				addGroup(aGroup, dBase)
-				fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in database: " + dBase + "...............\n")
-				// Update Inventory File
-				updateInventoryFile(ENV, dBase)
-				fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in database: " + dBase + ".\n")
-
-				os.Exit(0)
-
-			}
-		}
-	}
-
-	if os.Args[1] == "--attach-host" {
-		// get Database from stdin
-		dbReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter the database to which you wish to add the host: ")
-		dbName, _ := dbReader.ReadString('\n')
-		dBase := strings.Trim(dbName, "\n")
-
-		// get group from stdin
-		hostReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter the FQDN of the host you wish to attach: ")
-		hostName, _ := hostReader.ReadString('\n')
-		hName := strings.Trim(hostName, "\n")
-
-		// get group from stdin
-		groupReader := bufio.NewReader(os.Stdin)
-		fmt.Print("Enter the group to wich you wish to attach this host: ")
-		groupName, _ := groupReader.ReadString('\n')
-		gName := strings.Trim(groupName, "\n")
-
-		// ensure that the data store has been provided
-		if dBase != "provisioner" && dBase != "custodian" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a valid value for the datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		// validate environment
-		if !envExists(ENV, dBase) {
-			fmt.Println("\n[ ERROR ] --> The Environment: " + ENV + " does not exist in the database.\n")
-			os.Exit(1)
-		}
-
-		// validate host
-		if !hostExists(hName, ENV, dBase) {
-			fmt.Println("\n[ ERROR ] --> The Host: " + hName + " does not exist in Environment: " + ENV + " in database: " + dBase + ".\n")
-			fmt.Println("There is nothing to delete...Exiting.\n")
-			os.Exit(1)
-		}
-
-		// validate group
-		if !groupExists(gName, ENV, dBase) {
-			fmt.Println("\n[ ERROR ] --> The Group: " + gName + " does not exist in Environment: " + ENV + " in database: " + dBase + ".\n")
-			os.Exit(1)
-		}
-
-		// attach supplied host to the requested group
-		attachHost(hName, gName, ENV, dBase)
-
-		// Update Inventory File
-		fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in database: " + dBase + "...............\n")
-		updateInventoryFile(ENV, dBase)
-		fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in database: " + dBase + ".\n")
-
-		os.Exit(0)
+	// clerk help [command] is the same as clerk [command] -help
+	if args[0] == "help" {
+		args = append(args[1:], "-help")
 	}
 
-	if os.Args[1] == "--detach-host" {
-		// get Database from stdin
-		dbReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter the database to which you wish to add the host: ")
-		dbName, _ := dbReader.ReadString('\n')
-		dBase := strings.Trim(dbName, "\n")
-
-		// get group from stdin
-		hostReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter the FQDN of the host you wish to detach: ")
-		hostName, _ := hostReader.ReadString('\n')
-		hName := strings.Trim(hostName, "\n")
-
-		// get group from stdin
-		groupReader := bufio.NewReader(os.Stdin)
-		fmt.Print("Enter the group from which you wish to detach this host: ")
-		groupName, _ := groupReader.ReadString('\n')
-		gName := strings.Trim(groupName, "\n")
-
-		// ensure that the data store has been provided
-		if dBase != "provisioner" && dBase != "custodian" && dBase != "all" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a valid value for the datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		// validate environment
-		if !envExists(ENV, dBase) {
-			fmt.Println("\n[ ERROR ] --> The Environment: " + ENV + " does not exist in the database.\n")
-			os.Exit(1)
-		}
-
-		if dBase == "all" {
-			// validate group
-			if !groupExists(gName, ENV, "provisioner") {
-				fmt.Println("\n[ FAILED ] --> The Group: " + gName + " does not exist in Environment: " + ENV + " in datastore: provisioner.\n")
-				os.Exit(1)
-			}
-
-			if !groupExists(gName, ENV, "custodian") {
-				fmt.Println("\n[ FAILED ] --> The Group: " + gName + " does not exist in Environment: " + ENV + " in datastore: custodian.\n")
-				os.Exit(1)
-			}
-
-			// validate host
-			if !hostExists(hName, ENV, "provisioner") {
-				fmt.Println("\n[ FAILED ] --> The Host: " + hName + " does not exist in Environment: " + ENV + " in provisioner.\n")
-				os.Exit(1)
-			}
-
-			// validate host
-			if !hostExists(hName, ENV, "custodian") {
-				fmt.Println("\n[ FAILED ] --> The Host: " + hName + " does not exist in Environment: " + ENV + " in custodian.\n")
-				os.Exit(1)
-			}
-
-			// detach supplied host from the supplied group in all datastores
-			fmt.Println("\nDetaching host: " + hName + " from group: " + gName + " in provisioner............\n")
-			detachGroupFromHost(hName, gName, ENV, "provisioner")
-			detachHostFromGroup(hName, gName, ENV, "provisioner")
-			fmt.Println("\n[ OK] --> Successfully detached host: " + hName + " from group: " + gName + " in provisioner...........\n")
-			fmt.Println("\nDetaching host: " + hName + " from group: " + gName + " in custodian............\n")
-			detachGroupFromHost(hName, gName, ENV, "custodian")
-			detachHostFromGroup(hName, gName, ENV, "custodian")
-			fmt.Println("\n[ OK] --> Successfully detached host: " + hName + " from group: " + gName + " in custodian............\n")
-
-			fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " for provisioner...............\n")
-			// Update Inventory File
-			updateInventoryFile(ENV, "provisioner")
-			fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in provisioner\n")
-
-			fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " for custodian...............\n")
-			// Update Inventory File
-			updateInventoryFile(ENV, "custodian")
-			fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in custodian\n")
-
-			os.Exit(0)
-
-		} else {
-			if !groupExists(gName, ENV, dBase) {
-				fmt.Println("\n[ FAILED ] --> The Group: " + gName + " does not exist in Environment: " + ENV + " in datastore: " + dBase + ".\n")
-				os.Exit(1)
-			}
-
-			// validate host
-			if !hostExists(hName, ENV, dBase) {
-				fmt.Println("\n[ FAILED ] --> The Host: " + hName + " does not exist in Environment: " + ENV + " in " + dBase + ".\n")
-				os.Exit(1)
-			}
-
-			// detach supplied host from the supplied group in the datastore
-			fmt.Println("\nDetaching host: " + hName + " from group: " + gName + " from datastore: " + dBase + "............\n")
-			detachGroupFromHost(hName, gName, ENV, dBase)
-			detachHostFromGroup(hName, gName, ENV, dBase)
-			fmt.Println("\n[ OK] --> Successfully detached host: " + hName + " from group: " + gName + " in datastore: " + dBase + "............\n")
-
-			fmt.Println("\nUpdating Inventory file for Environment: " + ENV + "...............\n")
-			// Update Inventory File
-			updateInventoryFile(ENV, dBase)
-			fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + "\n")
+	args = translateLegacy(args)
+	cmd, rest := commands.find(args)
+	path := strings.TrimSpace("clerk " + strings.Join(args[:len(args)-len(rest)], " "))
 
+	if cmd.run == nil {
+		if len(rest) > 0 && (isSwitch(rest[0], "help") || isSwitch(rest[0], "h")) {
+			cmd.printHelp(os.Stdout, path, nil)
 			os.Exit(0)
-
-		}
-	}
-
-	if os.Args[1] == "--delete-host" {
-
-		// get group from stdin
-		hostReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter the FQDN of the host you wish to delete: ")
-		hostName, _ := hostReader.ReadString('\n')
-		hName := strings.Trim(hostName, "\n")
-
-		// get Database from stdin
-		dbReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter the database to which you wish to add the host: ")
-		dbName, _ := dbReader.ReadString('\n')
-		dBase := strings.Trim(dbName, "\n")
-
-		// ensure that the data store has been provided
-		if dBase != "provisioner" && dBase != "custodian" && dBase != "all" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a valid value for the datastore when it is required.\n")
-			os.Exit(1)
 		}
-
-		if !envExists(ENV, dBase) {
-			fmt.Println("\n[ ERROR ] --> The Environment: " + ENV + " does not exist in the database.\n")
-			os.Exit(1)
-		}
-
-		if !hostExists(hName, ENV, dBase) {
-			fmt.Println("\n[ FAILED ] --> The Host: " + hName + " does not exist in Environment: " + ENV + ".\n")
-			os.Exit(1)
+		if len(rest) > 0 {
+			fmt.Fprintln(os.Stderr, "\n[ ERROR ] --> Unknown command: "+path+" "+rest[0]+".")
 		}
-
-		// delete supplied host from the supplied group
-		fmt.Println("\nDeleting host: " + hName + "............\n")
-		deleteHost(hName, ENV, dBase)
-		fmt.Println("\n[ OK ] --> Successfully deleted host: " + hName + "\n")
-
-		fmt.Println("\n[ INFO] --> Updating Inventory file for Environment: " + ENV + "...............\n")
-		// Update Inventory File
-		updateInventoryFile(ENV, dBase)
-		fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + "\n")
-
-		os.Exit(0)
+		cmd.printHelp(os.Stderr, path, nil)
+		os.Exit(EXITUSAGE)
 	}
 
-	if os.Args[1] == "--delete-group" {
-
-		// get group from stdin
-		groupReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter the Groupname of the Group you wish to delete: ")
-		groupName, _ := groupReader.ReadString('\n')
-		gName := strings.Trim(groupName, "\n")
-
-		// get Database from stdin
-		dbReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter the database to which you wish to add the host: ")
-		dbName, _ := dbReader.ReadString('\n')
-		dBase := strings.Trim(dbName, "\n")
-
-		// ensure that the data store has been provided
-		if dBase != "provisioner" && dBase != "custodian" && dBase != "all" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a valid value for the datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		if dBase == "all" {
-			// validate environment
-			if !envExists(ENV, "privisioner") || !envExists(ENV, "custodian") {
-				fmt.Println("\n[ ERROR ] --> The Environment: " + ENV + " does not exist in all databases.\n")
-				fmt.Println("\n[ FATAL ERROR ] --> Please Ensure that your environments are set up correctly....Exiting.\n")
-				os.Exit(1)
-			}
-
-			// delete supplied group from the supplied environment
-			if groupExists(gName, ENV, "provisioner") {
-				fmt.Println("\n[ INFO ] --> Deleting group: " + gName + "from Environment: " + ENV + " in provisioner............\n")
-				deleteGroup(gName, ENV, "provisioner")
-				fmt.Println("\n[ OK ] --> Successfully deleted group: " + gName + " from Environment: " + ENV + " in provisioner.\n")
-
-				fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in provisioner...............\n")
-				// Update Inventory File
-				updateInventoryFile(ENV, "provisioner")
-				fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in provisioner.\n")
-			} else {
-				fmt.Println("\n[ INFO ] --> Group: " + gName + " does not exist in datastore: provisioner...skipping delete.\n")
-			}
-
-			if groupExists(gName, ENV, "custodian") {
-				fmt.Println("\n[ INFO ] --> Deleting group: " + gName + "from Environment: " + ENV + " in custodian............\n")
-				deleteGroup(gName, ENV, "custodian")
-				fmt.Println("\n[ OK ] --> Successfully deleted group: " + gName + " from Environment: " + ENV + "in custodian.\n")
-
-				fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in custodian...............\n")
-				// Update Inventory File
-				updateInventoryFile(ENV, "custodian")
-				fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in custodian.\n")
-
-			} else {
-				fmt.Println("\n[ INFO ] --> Group: " + gName + " does not exist in datastore: custodian...skipping delete.\n")
-			}
-
-			os.Exit(0)
-
-		} else {
-			// validate environment
-			if !envExists(ENV, dBase) {
-				fmt.Println("\n[ ERROR ] --> The Environment: " + ENV + " does not exist in the database: " + dBase + ".\n")
-				os.Exit(1)
-			}
-			// delete supplied group from the supplied environment
-			if groupExists(gName, ENV, dBase) {
-				fmt.Println("\n[ INFO ] --> Deleting group: " + gName + "from Environment: " + ENV + " in datastore: " + dBase + "............\n")
-				deleteGroup(gName, ENV, dBase)
-				fmt.Println("\n[ OK ] --> Successfully deleted group: " + gName + " from Environment: " + ENV + " in datastore: " + dBase + ".\n")
-
-				fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in " + dBase + "...............\n")
-				// Update Inventory File
-				updateInventoryFile(ENV, dBase)
-				fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in " + dBase + ".\n")
-
-				os.Exit(0)
-			} else {
-				fmt.Println("\n[ ERROR ] --> Failed to delete group: " + gName + " from " + dBase + "...group not found.\n")
-				os.Exit(1)
-			}
-
-		}
+	o := cmd.parse(path, rest)
+	cmd.complete(o)
 
+	if !validOutput(o.output) {
+		exit(EXITINVALID, "ERROR", "The output format: "+o.output+" is not one of table, json or yaml.")
 	}
 
-	if os.Args[1] == "--clone-host" {
-
-		// get group from stdin
-		templateReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter the FQDN of the host to use as a template: ")
-		templateName, _ := templateReader.ReadString('\n')
-		tName := strings.Trim(templateName, "\n")
-
-		// get group from stdin
-		hostReader := bufio.NewReader(os.Stdin)
-		fmt.Print("Enter the FQDN of the host you wish to add: ")
-		hostName, _ := hostReader.ReadString('\n')
-		hName := strings.Trim(hostName, "\n")
-
-		if *datastore == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		// create the new host using the supplied template host
-		cloneHost(tName, hName, ENV, *datastore)
-
-		fmt.Println("\nUpdating Inventory file for Environment: " + ENV + " in database: " + *datastore + "...............\n")
-		// Update Inventory File
-		updateInventoryFile(ENV, *datastore)
-		fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in database: " + *datastore + ".\n")
-
-		os.Exit(0)
+	loadConfig(o)
+	if cmd.takes("datastore") {
+		o.datastores = cmd.datastores(o)
 	}
 
-	if os.Args[1] == "--add-env" {
-		// get database from stdin
-		dbReader := bufio.NewReader(os.Stdin)
-		fmt.Print("\nEnter the name of the database you wish to add the environment to: ")
-		dbName, _ := dbReader.ReadString('\n')
-		dBase := strings.Trim(dbName, "\n")
-
-		if envExists(ENV, *datastore) {
-			fmt.Println("\n[ FAILED ] --> The environment: " + ENV + " already Exists in the database.\n")
-			os.Exit(1)
-		}
-
-		// create prefix
-		ePrefix := strings.ToLower(strings.Replace(ENV, "-", "_", -1))
-
-		// create AnsibleEnvironment struct and populate fields
-		anEnvironment := new(AnsibleEnvironment)
-		anEnvironment.Prefix = ePrefix
-		anEnvironment.Name = ENV
-
-		// attach supplied host to the requested group
-		addEnvironment(anEnvironment, dBase)
+	store := connect()
+	defer store.inv.Close()
 
-		fmt.Println("\n[ INFO ] --> Creating Inventory file for Environment: " + ENV + "...............\n")
-		// add Inventory file
-		createInventoryFile(anEnvironment.Name, dBase)
-		fmt.Println("\n[ OK ] --> Successfully Created Inventory File for " + ENV + ".\n")
-
-		os.Exit(0)
-	}
-
-	// Parse Flags -- Should only get here if not an interactive run
-	flag.Parse()
-
-	// Check what flags were supplied to determine function and ensure proper subflags were also supplied
-	if *addhost {
-		//ensure necessary sub-flag values were supplied
-		if *fqdn == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -fqdn when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *brepoversion == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -baseRepoVersion when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *urepoversion == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -updatesRepoVersion when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *erepoversion == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -extrasRepoVersion when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *prepoversion == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -plusRepoVersion when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *eplrepoversion == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -epelRepoVersion when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *machinearch == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -archType when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *ostype == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -osType when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *osversion == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -osVersion when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *datastore == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		// validate environment
-		if !envExists(ENV, *datastore) {
-			fmt.Println("\n[ FAILED ] --> The environment: " + ENV + " does not exist in the database: " + *datastore + ".\n")
-			os.Exit(1)
-		}
-
-		// validate host
-		if hostExists(*fqdn, ENV, *datastore) {
-			fmt.Println("\n[ FAILED ] --> The Host: " + *fqdn + " already exists in Environment: " + ENV + ".\n")
-			os.Exit(1)
-		}
-
-		groupsMap := make(map[string]bool)
-
-		aHost := AnsibleHost{Fqdn: *fqdn, Groups: groupsMap, Environment: ENV}
-		// we add the host before checking groups
-		addHost(aHost, *datastore)
-
-		if *groups != "EMPTY" {
-			if strings.Contains(*groups, ",") {
-				gList := strings.Split(*groups, ",")
-				for g := range gList {
-					// ensure group exists in the specified environment in the specified datastore before proceeding
-					if !groupExists(gList[g], ENV, *datastore) {
-						fmt.Println("\n[ ERROR ] --> The Group: " + gList[g] + " does not exist in Environment: " + ENV + " in datastore: " + *datastore + ".\n")
-						fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in " + *datastore + "...............\n")
-						updateInventoryFile(ENV, *datastore)
-						fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in " + *datastore + ".\n")
-						os.Exit(1)
-					}
-
-					attachHost(*fqdn, gList[g], ENV, *datastore)
-					fmt.Println("\n[ INFO ] -->Updating Inventory file for Environment: " + ENV + " in " + *datastore + "...............\n")
-					updateInventoryFile(ENV, *datastore)
-					fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in " + *datastore + ".\n")
-
-				}
-			} else if *groups != "" {
-				if !groupExists(*groups, ENV, *datastore) {
-					fmt.Println("\n[ ERROR ] --> The Group: " + *groups + " does not exist in Environment: " + ENV + " in datastore: " + *datastore + ".\n")
-					os.Exit(1)
-				}
-
-				attachHost(*fqdn, *groups, ENV, *datastore)
-			}
-		}
-
-		fmt.Println("\nUpdating Inventory file for Environment: " + ENV + " in " + *datastore + "...............\n")
-		updateInventoryFile(ENV, *datastore)
-		fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in " + *datastore + ".\n")
-
-		os.Exit(0)
-
-	}
-
-	if *listgroups {
-		// validate environment
-		if !envExists(ENV, *datastore) {
-			fmt.Println("\n[ ERROR ] --> The Environment: " + ENV + " does not exist in the database.\n")
-			os.Exit(1)
-		}
-
-		// validate Environment
-		if !envExists(ENV, *datastore) {
-			fmt.Println("\n[ FAILED ] --> The environment: " + ENV + " does not exist in the database.\n")
-			os.Exit(1)
-		}
-
-		// list groups and their description that exist in the supplied environment
-		listGroups(ENV, *datastore)
-		os.Exit(0)
-
-	}
-
-	if *listhostopts {
-		// ensure that the data store has been provided
-		if *datastore == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -datastore when it is required.\n")
-			os.Exit(1)
-		}
-		//Get list of hosts
-		listHostOptions(ENV, *datastore)
-		os.Exit(0)
-	}
-
-	if *listgroupopts {
-		// ensure that the data store has been provided
-		if *datastore == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -datastore when it is required.\n")
-			os.Exit(1)
-		}
-		// Get list of groups
-		listGroupOptions(ENV, *datastore)
-		os.Exit(0)
-	}
-
-	if *hostdetails {
-		// ensure that the data store has been provided
-		if *datastore == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		// ensure that a fully qualified hostname has been provided
-		if *fqdn == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -fqdn when it is required.\n")
-			os.Exit(1)
-		}
-
-		//display the host details
-		displayHost(*fqdn, ENV, *datastore)
-		os.Exit(0)
-	}
-
-	if *groupdetails {
-		// ensure that the data store has been provided
-		if *datastore == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		// ensure that a group has been provided
-		if *group == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -group when it is required.\n")
-			os.Exit(1)
-		}
-
-		// validate group
-		if !groupExists(*group, ENV, *datastore) {
-			fmt.Println("\n[ ERROR ] --> The Group: " + *group + " does not exist in Environment: " + ENV + " in datastore: " + *datastore + ".\n")
-			os.Exit(1)
-		}
-
-		//display the group details
-		displayGroup(*group, ENV, *datastore)
-		os.Exit(0)
-	}
-
-	if *attachhost {
-		//ensure necessary sub-flag values were supplied
-		if *fqdn == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -fqdn when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *groups == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -groups when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *datastore == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		// validate environment
-		if !envExists(ENV, *datastore) {
-			fmt.Println("\n[ ERROR ] --> The Environment: " + ENV + " does not exist in the database.\n")
-			os.Exit(1)
-		}
-
-		// validate host
-		if !hostExists(*fqdn, ENV, *datastore) {
-			fmt.Println("\n[ FAILED ] --> The Host: " + *fqdn + " does not exist in Environment: " + ENV + " in database: " + *datastore + ".\n")
-			fmt.Println("There is nothing to delete...Exiting.\n")
-			os.Exit(1)
-		}
-
-		if *groups != "EMPTY" {
-
-			if strings.Contains(*groups, ",") {
-				gList := strings.Split(*groups, ",")
-				for g := range gList {
-					if !groupExists(gList[g], ENV, *datastore) {
-						fmt.Println("\n[ FAILED ] --> The Group: " + gList[g] + " does not exist in Environment: " + ENV + ".\n")
-
-						// Update Inventory File
-						fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in " + *datastore + "...............\n")
-						updateInventoryFile(ENV, *datastore)
-						fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in " + *datastore + ".\n")
-						os.Exit(1)
-					}
-
-					attachHost(*fqdn, gList[g], ENV, *datastore)
-				}
-			} else if *groups != "" {
-				if !groupExists(*groups, ENV, *datastore) {
-					fmt.Println("\n[ FAILED ] --> The Group: " + *groups + " does not exist in Environment: " + ENV + " in " + *datastore + ".\n")
-					os.Exit(1)
-				}
-
-				attachHost(*fqdn, *groups, ENV, *datastore)
-			}
-		} else {
-			fmt.Println("\n[ FAILED ] -- > No group was supplied. You must supply a group to which to attach the host.\n")
-			os.Exit(1)
-
-		}
-
-		// Update Inventory File
-		fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in " + *datastore + "...............\n")
-		updateInventoryFile(ENV, *datastore)
-		fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in " + *datastore + ".\n")
-
-		os.Exit(0)
-	}
-
-	if *clonehost {
-
-		//ensure necessary sub-flag values were supplied
-		if *clone == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -clone when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *template == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -template when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *datastore == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		// create the new host using the supplied template host
-		cloneHost(*template, *clone, ENV, *datastore)
-
-		fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in " + *datastore + "...............\n")
-		// Update Inventory File
-		updateInventoryFile(ENV, *datastore)
-		fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in " + *datastore + ".\n")
-
-		os.Exit(0)
-	}
-
-	if *deletehost {
-
-		//ensure necessary sub-flag values were supplied
-		if *fqdn == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -fqdn when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *datastore == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		// Ensure environment is valid
-		if !envExists(ENV, *datastore) {
-			fmt.Println("\n[ ERROR ] --> The Environment: " + ENV + " does not exist in the database.\n")
-			os.Exit(1)
-		}
-
-		if !hostExists(*fqdn, ENV, *datastore) {
-			fmt.Println("\n[ FAILED ] --> The Host: " + *fqdn + " does not exist in Environment: " + ENV + ".\n")
-			os.Exit(1)
-		}
-
-		// delete supplied host from the supplied group
-		fmt.Println("\nDeleting host: " + *fqdn + "............\n")
-		deleteHost(*fqdn, ENV, *datastore)
-		fmt.Println("\n[ OK ] --> Successfully deleted host: " + *fqdn + "\n")
-
-		fmt.Println("\nUpdating Inventory file for Environment: " + ENV + " in database: " + *datastore + "...............\n")
-		// Update Inventory File
-		updateInventoryFile(ENV, *datastore)
-		fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in database: " + *datastore + ".\n")
-
-		os.Exit(0)
-
-	}
-
-	if *detachhost {
-		//ensure necessary sub-flag values were supplied
-		if *fqdn == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -fqdn when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *groups == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -groups when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *datastore == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		// validate environment
-		if !envExists(ENV, *datastore) {
-			fmt.Println("\n[ ERROR ] --> The Environment: " + ENV + " does not exist in the database.\n")
-			os.Exit(1)
-		}
-
-		// validate host
-		if !hostExists(*fqdn, ENV, *datastore) {
-			fmt.Println("\n[ FAILED ] --> The Host: " + *fqdn + " does not exist in Environment: " + ENV + ".\n")
-			os.Exit(1)
-		}
-
-		if *groups != "EMPTY" {
-
-			if strings.Contains(*groups, ",") {
-				gList := strings.Split(*groups, ",")
-				for g := range gList {
-					if !groupExists(gList[g], ENV, *datastore) {
-						fmt.Println("\n[ FAILED ] --> The Group: " + gList[g] + " does not exist in Environment: " + ENV + ".\n")
-						os.Exit(1)
-					}
-
-					detachGroupFromHost(*fqdn, gList[g], ENV, *datastore)
-					detachHostFromGroup(*fqdn, gList[g], ENV, *datastore)
-					fmt.Println("\n[ OK] --> Successfully detached host: " + *fqdn + " from group: " + gList[g] + " in " + *datastore + "............\n")
-				}
-			} else if *groups != "" {
-				if !groupExists(*groups, ENV, *datastore) {
-					fmt.Println("\n[ FAILED ] --> The Group: " + *groups + " does not exist in Environment: " + ENV + ".\n")
-					os.Exit(1)
-				}
-
-				detachGroupFromHost(*fqdn, *groups, ENV, *datastore)
-				detachHostFromGroup(*fqdn, *groups, ENV, *datastore)
-				fmt.Println("\n[ OK] --> Successfully detached host: " + *fqdn + " from group: " + *groups + "............\n")
-			}
-		} else {
-			fmt.Println("\n[ FAILED ] -- > No group was supplied. You must supply a group to which to attach the host.\n")
-			os.Exit(1)
-
-		}
-
-		fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in " + *datastore + "...............\n")
-		// Update Inventory File
-		updateInventoryFile(ENV, *datastore)
-		fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in " + *datastore + ".\n")
-		os.Exit(0)
-
-	}
-
-	if *push {
-		//ensure necessary sub-flag values were supplied
-		if *hosts == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -hosts when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *hosts != "EMPTY" {
-
-			if strings.Contains(*hosts, ",") {
-				hList := strings.Split(*hosts, ",")
-				for h := range hList {
-					if !hostExists(hList[h], ENV, "provisioner") {
-						fmt.Println("\n[ FAILED ] --> The Host: " + hList[h] + " does not exist in Environment: " + ENV + ".\n")
-						os.Exit(1)
-					}
-
-					pushOneHost(hList[h])
-				}
-			} else if *hosts != "" {
-				if !hostExists(*hosts, ENV, "provisioner") {
-					fmt.Println("\n[ FAILED ] --> The Host: " + *hosts + " does not exist in Environment: " + ENV + ".\n")
-					os.Exit(1)
-				}
-
-				pushOneHost(*hosts)
-			}
-		}
-
-		os.Exit(0)
-
-	}
-
-	if *pull {
-		//ensure necessary sub-flag values were supplied
-		if *hosts == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -hosts when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *hosts != "EMPTY" {
-
-			if strings.Contains(*hosts, ",") {
-				hList := strings.Split(*hosts, ",")
-				for h := range hList {
-					pullOneHost(hList[h])
-				}
-			} else if *hosts != "" {
-				pullOneHost(*hosts)
-			}
-		}
-
-		os.Exit(0)
-	}
-
-	if *addgroup {
-		//ensure necessary sub-flag values were supplied
-		if *group == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -group when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *description == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -description when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *datastore == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		// setup the group members map with empty members slice
-		groupMembers := map[string][]string{*group: make([]string, 0)}
-		aGroup := AnsibleGroups{Members: groupMembers, Description: *description, Environment: ENV, Name: *group}
-
-		if *datastore == "all" {
-			// validate environment
-			if !envExists(ENV, "provisioner") || !envExists(ENV, "custodian") {
-				fmt.Println("\n[ ERROR ] --> The Environment: " + ENV + " does not exist in all databases.\n")
-				fmt.Println("\n[ FATAL ERROR ] --> Please Ensure that your environments are set up correctly....Exiting.\n")
-				os.Exit(1)
-			}
-
-			// validate group
-			if groupExists(*group, ENV, "provisioner") && groupExists(*group, ENV, "custodian") {
-				fmt.Println("\n[ FAILED ] --> The Group: " + *group + "  already exists in Environment: " + ENV + " in all datastores.\n")
-				os.Exit(1)
-			}
-			if !groupExists(*group, ENV, "provisioner") {
-				addGroup(aGroup, "provisioner")
-				fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in provisioner...............\n")
-				// Update Inventory File
-				updateInventoryFile(ENV, "provisioner")
-				fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in provisioner.\n")
-
-			}
-			if !groupExists(*group, ENV, "custodian") {
-				addGroup(aGroup, "custodian")
-				fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in custodian...............\n")
-				// Update Inventory File
-				updateInventoryFile(ENV, "custodian")
-				fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in custodian.\n")
-
-			}
-
-			os.Exit(0)
-		} else {
-
-			if !envExists(ENV, *datastore) {
-				fmt.Println("\n[ ERROR ] --> The environment: " + ENV + " does not exist in the datastore: " + *datastore + ".\n")
-				os.Exit(1)
-			}
-
-			if !groupExists(*group, ENV, *datastore) {
-				// Add the group the requested environment
-				addGroup(aGroup, *datastore)
-				fmt.Println("\nUpdating Inventory file for Environment: " + ENV + " in " + *datastore + "...............\n")
-				// Update Inventory File
-				updateInventoryFile(ENV, *datastore)
-				fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in " + *datastore + ".\n")
-				os.Exit(0)
-
-			} else {
-				fmt.Println("\n[ FAILED ] --> The Group: " + *group + "  already exists in Environment: " + ENV + " in database: " + *datastore + ".\n")
-				os.Exit(1)
-			}
-		}
-	}
-
-	if *deletegroup {
-		//ensure necessary sub-flag values were supplied
-		if *group == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -group when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *datastore == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *datastore == "all" {
-			// validate environment
-			if !envExists(ENV, "provisioner") || !envExists(ENV, "custodian") {
-				fmt.Println("\n[ ERROR ] --> The Environment: " + ENV + " does not exist in all databases.\n")
-				fmt.Println("\n[ FATAL ERROR ] --> Please Ensure that your environments are set up correctly....Exiting.\n")
-				os.Exit(1)
-			}
-			if groupExists(*group, ENV, "provisioner") {
-				// delete supplied group from the supplied environment in all datastores
-				fmt.Println("\n[ INFO ] --> Deleting group: " + *group + " from Environment: " + ENV + " in provisioner............\n")
-				deleteGroup(*group, ENV, "provisioner")
-				fmt.Println("\n[ OK ] --> Successfully deleted group: " + *group + " from Environment: " + ENV + " in provisioner.\n")
-
-				// Update Inventory File
-				fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in provisioner...............\n")
-				updateInventoryFile(ENV, "provisioner")
-				fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in provisioner.\n")
-
-			} else {
-				fmt.Println("\n[ INFO ] --> Group: " + *group + " does not exist in datastore: provisioner...skipping delete.\n")
-			}
-
-			if groupExists(*group, ENV, "custodian") {
-				//delete supplied group from the supplied environment in custodian
-				fmt.Println("\n[ INFO ] --> Deleting group: " + *group + " from Environment: " + ENV + "in custodian............\n")
-				deleteGroup(*group, ENV, "custodian")
-				fmt.Println("\n[ OK ] --> Successfully deleted group: " + *group + " from Environment: " + ENV + " in custodain.\n")
-
-				// Update Inventory File
-				fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in custodian...............\n")
-				updateInventoryFile(ENV, "custodian")
-				fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in custodian.\n")
-
-			} else {
-				fmt.Println("\n[ INFO ] --> Group: " + *group + " does not exist in datastore: custodian...skipping delete.\n")
-			}
-
-			os.Exit(0)
-
-		} else {
-			if !envExists(ENV, *datastore) {
-				fmt.Println("\n[ ERROR ] --> The environment: " + ENV + " does not exist in the datastore: " + *datastore + ".\n")
-				os.Exit(1)
-			}
-			if groupExists(*group, ENV, *datastore) {
-				// delete supplied group from the supplied environment in datastore
-				fmt.Println("\n[ INFO ] --> Deleting group: " + *group + " from Environment: " + ENV + " in datastore: " + *datastore + "............\n")
-				deleteGroup(*group, ENV, *datastore)
-				fmt.Println("\n[ OK ] --> Successfully deleted group: " + *group + " from Environment: " + ENV + " in datastore: " + *datastore + ".\n")
-
-				// Update Inventory File
-				fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in " + *datastore + "...............\n")
-				updateInventoryFile(ENV, *datastore)
-				fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in " + *datastore + ".\n")
-				os.Exit(0)
-			} else {
-				fmt.Println("\n[ ERROR ] --> Group: " + *group + " does not exist in datastore: " + *datastore + "...skipping delete.\n")
-				os.Exit(1)
-			}
-		}
-
-	}
-
-	if *movehost {
-		//ensure necessary sub-flag values were supplied
-		if *fqdn == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -fqdn when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *togroup == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -to-group when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *fromgroup == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -from-group when it is required.\n")
-			os.Exit(1)
-		}
-
-		if *datastore == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -datastore when it is required.\n")
-			os.Exit(1)
-		}
-
-		// validate environment
-		if !envExists(ENV, *datastore) {
-			fmt.Println("\n[ ERROR ] --> The Environment: " + ENV + " does not exist in the database.\n")
-			os.Exit(1)
-		}
-
-		// validate host
-		if !hostExists(*fqdn, ENV, *datastore) {
-			fmt.Println("\n[ FAILED ] --> The Host: " + *fqdn + " does not exist in Environment: " + ENV + ".\n")
-			os.Exit(1)
-		}
-
-		// validate group to which the host will be copied
-		if !groupExists(*togroup, ENV, *datastore) {
-			fmt.Println("\n[ FAILED ] --> The Group: " + *togroup + " does not exist in Environment: " + ENV + ".\n")
-			os.Exit(1)
-		}
-
-		// validate group from which the host will be removed
-		if !groupExists(*fromgroup, ENV, *datastore) {
-			fmt.Println("\n[ FAILED ] --> The Group: " + *fromgroup + " does not exist in Environment: " + ENV + ".\n")
-			os.Exit(1)
-		}
-
-		// detach supplied host from the supplied group
-		fmt.Println("\nDetaching host: " + *fqdn + " from group: " + *fromgroup + "............\n")
-		detachGroupFromHost(*fqdn, *fromgroup, ENV, *datastore)
-		detachHostFromGroup(*fqdn, *fromgroup, ENV, *datastore)
-		fmt.Println("\n[ OK] --> Successfully detached host: " + *fqdn + " from group: " + *fromgroup + "............\n")
-
-		// attach supplied host to the requested group
-		attachHost(*fqdn, *togroup, ENV, *datastore)
-
-		// Update Inventory File
-		fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in " + *datastore + "...............\n")
-		updateInventoryFile(ENV, *datastore)
-		fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in " + *datastore + ".\n")
-
-		os.Exit(0)
-
-	}
-
-	if *addenv {
-		// ensure that the data store has been provided
-		if *datastore == "EMPTY" {
-			fmt.Println("\n[ ERROR ] --> You did not provide a value for the sub-flag -dataStore when it is required.\n")
-			os.Exit(1)
-		}
-
-		if envExists(*environment, *datastore) {
-			fmt.Println("\n[ FAILED ] --> The environment: " + *environment + " already Exists in the database.\n")
-			os.Exit(1)
-		}
-
-		// create prefix
-		ePrefix := strings.ToLower(strings.Replace(*environment, "-", "_", -1))
-
-		// create AnsibleEnvironment struct and populate fields
-		anEnvironment := new(AnsibleEnvironment)
-		anEnvironment.Prefix = ePrefix
-		anEnvironment.Name = *environment
-
-		if *datastore == "all" {
-			addEnvironment(anEnvironment, "provisioner")
-			fmt.Println("\n[ INFO ] --> Creating Inventory file for Environment: " + ENV + " in provisioner.......\n")
-			// add Inventory file
-			createInventoryFile(anEnvironment.Name, "provisioner")
-			fmt.Println("\n[ OK ] --> Successfully Created Inventory File for " + ENV + " in provisioner.\n")
-
-			addEnvironment(anEnvironment, "custodian")
-			fmt.Println("\n[ INFO ] --> Creating Inventory file for Environment: " + ENV + " in custodian.......\n")
-			// add Inventory file
-			createInventoryFile(anEnvironment.Name, "custodian")
-			fmt.Println("\n[ OK ] --> Successfully Created Inventory File for " + ENV + " in custodian.\n")
-		} else {
-			addEnvironment(anEnvironment, *datastore)
-			fmt.Println("\n[ INFO ] --> Creating Inventory file for Environment: " + ENV + "...............\n")
-			// add Inventory file
-			createInventoryFile(anEnvironment.Name, *datastore)
-			fmt.Println("\n[ OK ] --> Successfully Created Inventory File for " + ENV + ".\n")
-		}
-
-		os.Exit(0)
+	if o.dryRun {
+		store.dryRun(cmd, o)
+		return
 	}
-
+	cmd.run(store, o)
 }
 
-func listInventory() {
-	envGroupsDb := strings.ToLower(strings.Replace(ENV, "-", "_", -1)) + "_groups"
-
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
-	}
-	defer session.Close()
-
-	// attatch session to desired database and collection
-	c := session.DB("provisioner").C(envGroupsDb)
-
-	// get Iterator of items in the collection
-	iter := c.Find(nil).Iter()
-	var ansibleGrps AnsibleGroups
-	groupsSlice := make(map[string][]string)
-	for iter.Next(&ansibleGrps) {
-		for k := range ansibleGrps.Members {
-			groupsSlice[k] = ansibleGrps.Members[k]
+// answers the two calls of the Ansible dynamic inventory protocol: --list prints the
+// inventory of the environment and --host <fqdn> the variables of a host
+func runAnsible(args []string) {
+	hostName := ""
+	if len(args) > 0 && isSwitch(args[0], "host") {
+		if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+			exit(EXITUSAGE, "ERROR", "This switch requires a single parameter that is a hostname.")
 		}
+		hostName = args[1]
+		args = args[1:]
 	}
-
-	// convert groupsSlice map to nicely formated json
-	b, err := json.MarshalIndent(groupsSlice, "", "   ")
-	if err != nil {
-		fmt.Println("error:", err)
-	}
-
-	// print json group document
-	os.Stdout.Write(b)
-}
-
-func listHostVars() {
-	varMap := make(map[string]string)
-	b, err := json.Marshal(varMap)
-
-	if err != nil {
-		fmt.Println("error: ", err)
-	}
-
-	os.Stdout.Write(b)
-
-}
-
-func addHost(newHost AnsibleHost, database string) {
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
-	}
-	defer session.Close()
-
-	// set up hosts collection reference
-	hCollection := strings.ToLower(strings.Replace(newHost.Environment, "-", "_", -1)) + "_hosts"
-
-	// attatch session to desired database and collection
-	c := session.DB(database).C(hCollection)
-
-	fmt.Println("\n[ INFO ] --> Adding " + newHost.Fqdn + " to Environment: " + newHost.Environment + " in database: " + database + "......\n")
-	err = c.Insert(&newHost)
-	if err != nil {
-		fmt.Println("\n[ ERROR ] -- Failed to add host to database: " + database + ".\n")
-		os.Exit(1)
-	}
-
-	fmt.Println("\n[ OK ] --> Successfully added " + newHost.Fqdn + " to " + newHost.Environment + "\n")
-
-	// attach new host to default environment _all group
-	allGroup := strings.ToLower(strings.Replace(newHost.Environment, "-", "_", -1)) + "_all"
-	attachHost(newHost.Fqdn, allGroup, newHost.Environment, database)
-
-}
-
-func addEnvironment(newEnv *AnsibleEnvironment, database string) {
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
-	}
-	defer session.Close()
-
-	// attatch session to desired database and collection
-	c := session.DB(database).C("environments")
-
-	fmt.Println("\nAdding Environment " + newEnv.Name + " to Inventory........")
-	err = c.Insert(&newEnv)
-	if err != nil {
-		fmt.Println("\n[ ERROR ] -- Failed to add Environment to database.\n")
-		panic(err)
-	}
-	fmt.Println("\n[ OK ] -- successfully added Environment: " + newEnv.Name + "\n")
-
-	// set up group session
-	allGroup := AnsibleGroups{}
-	allGroup.Name = strings.ToLower(strings.Replace(newEnv.Name, "-", "_", -1)) + "_all"
-
-	// setup the group members map with empty members slice
-	groupMembers := map[string][]string{allGroup.Name: make([]string, 0)}
-	allGroup.Members = groupMembers
-	allGroup.Description = "Default Group for all members in " + newEnv.Name
-	allGroup.Environment = newEnv.Name
-
-	// Add the group the requested environment
-	addGroup(allGroup, database)
-
-}
-
-func listGroups(ansibleEnv, database string) {
-
-	// Set up groups collection
-	groupsCollection := strings.ToLower(strings.Replace(ansibleEnv, "-", "_", -1)) + "_groups"
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
-	}
-	defer session.Close()
-
-	// attatch session to desired database and collection
-	c := session.DB(database).C(groupsCollection)
-
-	// header
-	fmt.Println("\n--BEGIN--\n")
-	fmt.Println("\n=====================   [ " + ansibleEnv + " ]   =====================\n")
-
-	// get Iterator of groups in the environment groups collection
-	iter := c.Find(nil).Iter()
-	var ansibleGrps AnsibleGroups
-	for iter.Next(&ansibleGrps) {
-		// Print out each group name and its description
-		fmt.Println("\n| Groupname: " + ansibleGrps.Name)
-		fmt.Println("| Description: " + ansibleGrps.Description + "\n|")
-	}
-
-	// footer
-	fmt.Println("\n\n\n--END--\n")
-
-}
-
-func addGroup(newGroup AnsibleGroups, database string) {
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
-	}
-	defer session.Close()
-
-	// set up groups collection reference
-	gCollection := strings.ToLower(strings.Replace(ENV, "-", "_", -1)) + "_groups"
-
-	// attatch session to desired database and collection
-	c := session.DB(database).C(gCollection)
-
-	// add group to database
-	fmt.Println("\n[ INFO ] --> Adding Group " + newGroup.Name + " to the Inventory Environment " + ENV + " in datastore: " + database + "......\n")
-	err = c.Insert(&newGroup)
-
-	// check for errors
-	if err != nil {
-		fmt.Println("\n[ ERROR ] -- Failed to add group to database: " + database + ".\n")
-		panic(err)
-	}
-	fmt.Println("\n[ OK ] --> Successfully added group: " + newGroup.Name + " to the environment: " + ENV + ".\n")
-
-	fmt.Println("\n[ INFO ] --> Associating group: " + newGroup.Name + " to Environment: " + ENV + " in datastore: " + database + ".\n")
-	assocGroupToEnv(newGroup.Name, ENV, database)
-	fmt.Println("\n[ OK ] -- Successfully associated group: " + newGroup.Name + " to Environment: " + ENV + " in datastore: " + database + "\n")
-}
-
-func cloneHost(templateName, hostName, envName, database string) {
-
-	// setup db prefix
-	envDbPrefix := strings.ToLower(strings.Replace(envName, "-", "_", -1))
-
-	// setup host collection reference
-	envHostsCollection := envDbPrefix + "_hosts"
-
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
-	}
-	defer session.Close()
-
-	// attatch session to desired database and collection
-	c := session.DB(database).C(envHostsCollection)
-
-	result := AnsibleHost{}
-	// executes the query and returns single match
-	err = c.Find(bson.M{"fqdn": templateName}).One(&result)
-	if err != nil {
-		panic(err)
-	}
-
-	// copy template host field values to new host except Fqdn field value
-	newHost := AnsibleHost{}
-	newHost.Groups = result.Groups
-	newHost.Environment = result.Environment
-	newHost.Fqdn = hostName
-
-	// add New Host to database
-	fmt.Println("\n[ INFO ] --> Creating New host: " + newHost.Fqdn + " from Template host: " + templateName + "\n")
-	err = c.Insert(&newHost)
-	if err != nil {
-		panic(err)
-	}
-
-	fmt.Println("\n[ OK ] --> Successfully created " + newHost.Fqdn + " from Template: " + templateName + "\n")
-
-	for k := range newHost.Groups {
-		fmt.Println("\nAttaching " + hostName + " to group: " + k + "\n")
-		assocHostToGroup(hostName, k, envDbPrefix, database)
-		fmt.Println("\n[ OK ] --> Successfully attached " + hostName + " to group: " + k + "\n")
-
-	}
-
-}
-
-func attachHost(hostName string, groupName string, envName string, database string) {
-	// Set up Prefix and host collection details
-	envDbPrefix := strings.ToLower(strings.Replace(envName, "-", "_", -1))
-	envHostsCollection := envDbPrefix + "_hosts"
-
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
-	}
-	defer session.Close()
-
-	// attatch session to desired database and collection
-	c := session.DB(database).C(envHostsCollection)
-
-	result := AnsibleHost{}
-	// executes the query and returns single match
-	err = c.Find(bson.M{"fqdn": hostName}).One(&result)
-	if err != nil {
-		fmt.Println("\n[ ERROR ] --> Failed to find host: " + hostName + " in database: " + database + ".....Exiting.\n")
-		os.Exit(1)
-	}
-
-	// adds host to group
-	fmt.Println("\nAttaching " + hostName + " to " + groupName + "............\n")
-
-	if !result.Groups[groupName] {
-		result.Groups[groupName] = true
-		assocHostToGroup(hostName, groupName, envDbPrefix, database)
-		assocGroupToHost(hostName, groupName, envDbPrefix, database)
-	}
-
-	fmt.Println("\n[ OK ] --> Successfully attached " + hostName + " to " + groupName + " \n")
-
-}
-
-func assocGroupToHost(hostName, groupName, envDbPrefix, database string) {
-	hostsCollection := envDbPrefix + "_hosts"
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		fmt.Println("\n[ ERROR ] --> Failed to connect to MongoDB at: " + MONGOIP + "\n")
-		os.Exit(1)
-	}
-	defer session.Close()
-
-	// attatch session to desired database and collection
-	c := session.DB(database).C(hostsCollection)
-
-	result := AnsibleHost{}
-	// executes the query and returns single match
-	err = c.Find(bson.M{"fqdn": hostName}).One(&result)
-	if err != nil {
-		fmt.Println("\nFailed to find host: " + hostName + " in database: " + database + " \n")
-		os.Exit(1)
-	}
-
-	if !result.Groups[groupName] {
-		result.Groups[groupName] = true
-	}
-
-	err = c.Update(bson.M{"fqdn": hostName}, bson.M{"fqdn": result.Fqdn, "groups": result.Groups, "environment": result.Environment})
-
-	if err != nil {
-		fmt.Println("\n[ ERROR ] --> Failed to associate group: " + groupName + " to host: " + hostName + " in database: " + database + " \n")
-		os.Exit(1)
-	}
-
-	fmt.Println("\n[ OK ] --> Successfully associated group: " + groupName + " to host: " + hostName + " in database: " + database + "\n")
-}
-
-func assocHostToGroup(hostName, groupName, envDbPrefix, database string) {
-	groupsCollection := envDbPrefix + "_groups"
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
+	if len(args) > 0 {
+		args = args[1:]
 	}
-	defer session.Close()
 
-	// attatch session to desired database and collection
-	c := session.DB(database).C(groupsCollection)
-
-	result := AnsibleGroups{}
-	// executes the query and returns single match
-	err = c.Find(bson.M{"name": groupName}).One(&result)
-	if err != nil {
-		panic(err)
+	// the switches take the common flags after the switch (and after the hostname for --host)
+	o := &options{}
+	fs := flag.NewFlagSet("clerk", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addCommonFlags(fs, o)
+	if err := fs.Parse(args); err != nil && !errors.Is(err, flag.ErrHelp) {
+		exit(EXITUSAGE, "ERROR", err.Error()+".")
 	}
 
-	//add member to updated group -- added to members group slice
-	result.Members[groupName] = append(result.Members[groupName], hostName)
+	loadConfig(o)
+	store := connect()
+	defer store.inv.Close()
 
-	err = c.Update(bson.M{"name": groupName}, bson.M{"name": result.Name, "members": result.Members, "description": result.Description, "environment": result.Environment})
-
-	if err != nil {
-		fmt.Println("[ ERROR ] --> Failed to Update Group with new host")
-		os.Exit(1)
+	if hostName != "" {
+		store.listHostVars(hostName)
+		return
 	}
+	store.listInventory()
+}
 
+// reports whether an argument is the switch name given with one or two dashes
+func isSwitch(arg, name string) bool {
+	return arg == "-"+name || arg == "--"+name
 }
 
-func assocGroupToEnv(gName, gEnv, database string) {
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
+// loads the configuration of this installation and selects the environment to run against
+func loadConfig(o *options) {
+	var err error
+	CONFIG, err = inventory.LoadConfig(o.config)
 	if err != nil {
-		panic(err)
+		exit(EXITCONFIG, "ERROR", "Failed to load the configuration: "+err.Error())
 	}
-	defer session.Close()
 
-	// attatch session to desired database and collection
-	c := session.DB(database).C("environments")
+	ENV = selectEnvironment(o.environment)
+}
 
-	result := AnsibleEnvironment{}
-	// executes the query and returns single match
-	err = c.Find(bson.M{"name": gEnv}).One(&result)
+// opens the single session shared by every operation of this run
+func connect() *clerk {
+	inv, err := inventory.Open(CONFIG)
+	if errors.Is(err, inventory.ErrInvalidConfig) {
+		exit(EXITCONFIG, "FAILED", "Unable to open the datastores: "+err.Error())
+	}
 	if err != nil {
-		panic(err)
+		exit(EXITUNAVAILABLE, "FAILED", "Unable to obtain a connection to the datastores: "+err.Error())
 	}
 
-	// create update Environment and copy values from pre-updated Environment
-	uEnv := AnsibleEnvironment{}
-	uEnv.Name = result.Name
-	uEnv.Prefix = result.Prefix
-	uEnv.Groups = result.Groups
+	// a change that was made goes on to update the inventory file when it could not be audited
+	inv.SetAuditWarning(func(err error) {
+		fmt.Fprint(os.Stderr, "\n[ WARNING ] --> "+err.Error()+".\n\n")
+	})
+	return &clerk{inv: inv}
+}
 
-	// update the Groups Map to include the new Group if not already there
-	if !uEnv.Groups[gName] {
-		uEnv.Groups[gName] = true
+// selects the environment to run against: the -environment flag, then $CAPERNICUS_ENV, then the configured default
+func selectEnvironment(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
 	}
 
-	// replace existing environment with the updated environment
-	err = c.Update(bson.M{"name": gEnv}, bson.M{"name": uEnv.Name, "groups": uEnv.Groups, "prefix": uEnv.Prefix})
-
-	if err != nil {
-		fmt.Println("Failed to update Environment: " + gEnv + " in database: " + database + "\n")
-		os.Exit(1)
+	if envName := os.Getenv("CAPERNICUS_ENV"); envName != "" {
+		return envName
 	}
 
-	fmt.Println("\n[ OK ] --> Successfully updated Environment: " + ENV + " in database: " + database + ".\n")
-
+	return CONFIG.Environment
 }
 
-func displayHost(hName, hEnv, database string) {
+// builds the host filter from the host fact flags, unset facts match any host
+func hostFilter(osType, osVersion, archType string) inventory.HostFilter {
+	return inventory.HostFilter{OsType: osType, OsVersion: osVersion, ArchType: archType}
+}
 
-	colName := strings.ToLower(strings.Replace(hEnv, "-", "_", -1)) + "_hosts"
+// Exit codes of clerk, documented in the README, so that automation can tell why a run failed
+const (
+	EXITFAILURE     int = 1 // unexpected failure, such as an inventory file that cannot be written
+	EXITUSAGE       int = 2 // unknown command, flag or argument
+	EXITINVALID     int = 3 // missing or invalid value, such as a bad name or a cycle of groups
+	EXITNOTFOUND    int = 4 // the host, group or environment does not exist
+	EXITCONFLICT    int = 5 // the host, group or environment already exists or is still in use
+	EXITUNAVAILABLE int = 6 // the datastore could not be reached or timed out
+	EXITUNFINISHED  int = 7 // a push or pull was left unfinished, see clerk resume
+	EXITCONFIG      int = 8 // the configuration cannot be loaded or used
+)
 
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
+// returns the exit code for an error of the inventory package
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, inventory.ErrUnfinished):
+		return EXITUNFINISHED
+	case errors.Is(err, inventory.ErrUnavailable):
+		return EXITUNAVAILABLE
+	case errors.Is(err, inventory.ErrNotFound):
+		return EXITNOTFOUND
+	case errors.Is(err, inventory.ErrAlreadyExists), errors.Is(err, inventory.ErrInUse):
+		return EXITCONFLICT
+	case errors.Is(err, inventory.ErrInvalidName), errors.Is(err, inventory.ErrCycle), errors.Is(err, inventory.ErrMalformed):
+		return EXITINVALID
+	case errors.Is(err, inventory.ErrInvalidConfig):
+		return EXITCONFIG
 	}
-	defer session.Close()
+	return EXITFAILURE
+}
 
-	// attatch session to desired database and collection
-	c := session.DB(database).C(colName)
+// prints an error message on stderr and exits with code
+func exit(code int, label, message string) {
+	fmt.Fprint(os.Stderr, "\n[ "+label+" ] --> "+message+"\n\n")
+	os.Exit(code)
+}
+
+// prints the error of a failed operation and exits with its exit code
+func fail(err error) {
+	exit(exitCode(err), "ERROR", err.Error()+".")
+}
+
+// clerk runs the operations of the inventory store for the command line, printing the
+// outcome of every operation and exiting when one fails
+type clerk struct {
+	inv *inventory.Store
+}
 
-	result := AnsibleHost{}
-	// executes the query and returns single match
-	err = c.Find(bson.M{"fqdn": hName}).One(&result)
+func (c *clerk) listInventory() {
+	inv, err := c.inv.Inventory(ENV, inventory.PROVISIONER)
 	if err != nil {
-		panic(err)
+		fail(err)
 	}
 
-	fmt.Println("\n--BEGIN--\n|\n=====================   [ Details ]   =====================\n|")
-	fmt.Println("| Hostname: " + result.Fqdn + "\n| Environment: " + result.Environment + "\n|\n|")
-	fmt.Println("|\n=====================   [ Groups ]   ======================\n|")
-	for k := range result.Groups {
-		fmt.Println("| " + k)
+	// convert inventory to nicely formated json
+	b, err := json.MarshalIndent(inv, "", "   ")
+	if err != nil {
+		fail(err)
 	}
-	fmt.Println("|\n|\n|\n--END--\n")
 
+	// print json group document
+	os.Stdout.Write(b)
 }
 
-func displayGroup(gName, gEnv, database string) {
-
-	colName := strings.ToLower(strings.Replace(gEnv, "-", "_", -1)) + "_groups"
-
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
+func (c *clerk) listHostVars(hostName string) {
+	// ansible expects an empty variable map for a host it does not know
+	varMap, err := c.inv.HostVars(hostName, ENV, inventory.PROVISIONER)
+	if errors.Is(err, inventory.ErrNotFound) {
+		varMap, err = make(map[string]string), nil
+	}
 	if err != nil {
-		panic(err)
+		fail(err)
 	}
-	defer session.Close()
-
-	// attatch session to desired database and collection
-	c := session.DB(database).C(colName)
 
-	result := AnsibleGroups{}
-	// executes the query and returns single match
-	err = c.Find(bson.M{"name": gName}).One(&result)
+	b, err := json.Marshal(varMap)
 	if err != nil {
-		panic(err)
+		fail(err)
 	}
 
-	fmt.Println("\n--BEGIN--\n\n=====================   [ Details ]   =====================\n|")
-	fmt.Println("| Groupname: " + result.Name + "\n| Description: " + result.Description + "\n| Environment: " + result.Environment + "\n|\n|")
-	fmt.Println("|\n=====================   [ Hosts ]   ======================\n|")
-	memberSlice := result.Members[gName]
-	for _, item := range memberSlice {
-		fmt.Println("| " + item)
-	}
-	fmt.Println("|\n|\n\n--END--\n")
+	os.Stdout.Write(b)
 
 }
 
-func listGroupOptions(ansibleEnv, database string) {
-	// Set up groups collection
-	groupsCollection := strings.ToLower(strings.Replace(ansibleEnv, "-", "_", -1)) + "_groups"
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
+func (c *clerk) setHostVars(hostName, envName, database string, varMap map[string]string) {
+	fmt.Println("\n[ INFO ] --> Setting variables on host: " + hostName + " in database: " + database + "......\n")
+	if err := c.inv.SetHostVars(hostName, envName, database, varMap); err != nil {
+		fail(err)
 	}
-	defer session.Close()
 
-	// attatch session to desired database and collection
-	c := session.DB(database).C(groupsCollection)
-
-	// get Iterator of groups in the environment groups collection
-	iter := c.Find(nil).Iter()
-	var ansibleGrps AnsibleGroups
-	for iter.Next(&ansibleGrps) {
-		// Print out each group name
-		fmt.Println(ansibleGrps.Name)
-	}
+	fmt.Println("\n[ OK ] --> Successfully set variables on host: " + hostName + "\n")
 }
 
-func listHostOptions(ansibleEnv, database string) {
-	// Set up groups collection
-	hostsCollection := strings.ToLower(strings.Replace(ansibleEnv, "-", "_", -1)) + "_hosts"
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
+func (c *clerk) unsetHostVars(hostName, envName, database string, keys []string) {
+	fmt.Println("\n[ INFO ] --> Removing variables from host: " + hostName + " in database: " + database + "......\n")
+	if err := c.inv.UnsetHostVars(hostName, envName, database, keys); err != nil {
+		fail(err)
 	}
-	defer session.Close()
 
-	// attatch session to desired database and collection
-	c := session.DB(database).C(hostsCollection)
+	fmt.Println("\n[ OK ] --> Successfully removed variables from host: " + hostName + "\n")
+}
 
-	// get Iterator of groups in the environment groups collection
-	iter := c.Find(nil).Iter()
-	var ansibleHost AnsibleHost
-	for iter.Next(&ansibleHost) {
-		// Print out each host name
-		fmt.Println(ansibleHost.Fqdn)
+func (c *clerk) addHost(newHost inventory.AnsibleHost, database string) {
+	fmt.Println("\n[ INFO ] --> Adding " + newHost.Fqdn + " to Environment: " + newHost.Environment + " in database: " + database + "......\n")
+	if err := c.inv.AddHost(newHost, database); err != nil {
+		fail(err)
 	}
 
+	fmt.Println("\n[ OK ] --> Successfully added " + newHost.Fqdn + " to " + newHost.Environment + "\n")
 }
 
-func detachGroupFromHost(hostName, groupName, envName, database string) {
-	// setup db prefix
-	envDbPrefix := strings.ToLower(strings.Replace(envName, "-", "_", -1))
+func (c *clerk) addEnvironment(newEnv *inventory.AnsibleEnvironment, database string) {
+	fmt.Println("\nAdding Environment " + newEnv.Name + " to Inventory........")
+	if err := c.inv.AddEnvironment(newEnv, database); err != nil {
+		fail(err)
+	}
 
-	// setup host collection reference
-	envHostsCollection := envDbPrefix + "_hosts"
+	fmt.Println("\n[ OK ] -- successfully added Environment: " + newEnv.Name + "\n")
+}
 
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
+func (c *clerk) listGroups(ansibleEnv, database, format string) {
+	groups, err := c.inv.Groups(ansibleEnv, database)
 	if err != nil {
-		panic(err)
+		fail(err)
 	}
-	defer session.Close()
-
-	// attatch session to desired database and collection
-	c := session.DB(database).C(envHostsCollection)
 
-	result := AnsibleHost{}
-	// executes the query and returns single match
-	err = c.Find(bson.M{"fqdn": hostName}).One(&result)
-	if err != nil {
-		fmt.Println("\n[ ERROR ] --> Failed to find host: " + hostName + " in " + database + "....skipping group detach.\n")
+	if format == JSONOUTPUT || format == YAMLOUTPUT {
+		result := make([]groupOutput, 0, len(groups))
+		for _, ansibleGrp := range groups {
+			result = append(result, newGroupOutput(ansibleGrp, database))
+		}
+		printOutput(format, result)
 		return
 	}
 
-	// remove group from hosts groups Map
-	if result.Groups[groupName] {
-		delete(result.Groups, groupName)
-	}
+	// header
+	fmt.Print("\n--BEGIN--\n\n")
+	fmt.Println("\n=====================   [ " + ansibleEnv + " ]   =====================\n")
 
-	// updating host
-	err = c.Update(bson.M{"fqdn": result.Fqdn}, bson.M{"fqdn": result.Fqdn, "groups": result.Groups, "environment": result.Environment})
-	if err != nil {
-		panic(err)
+	for _, ansibleGrp := range groups {
+		// Print out each group name and its description
+		fmt.Println("\n| Groupname: " + ansibleGrp.Name)
+		fmt.Println("| Description: " + ansibleGrp.Description + "\n|")
 	}
-}
 
-func detachHostFromGroup(hostName, groupName, envName, database string) {
-	// setup db prefix
-	envDbPrefix := strings.ToLower(strings.Replace(envName, "-", "_", -1))
+	// footer
+	fmt.Print("\n\n\n--END--\n\n")
 
-	// setup Group Collection reference
-	groupsCollection := envDbPrefix + "_groups"
+}
 
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
+func (c *clerk) addGroup(newGroup inventory.AnsibleGroups, database string) {
+	fmt.Println("\n[ INFO ] --> Adding Group " + newGroup.Name + " to the Inventory Environment " + newGroup.Environment + " in datastore: " + database + "......\n")
+	if err := c.inv.AddGroup(newGroup, database); err != nil {
+		fail(err)
 	}
-	defer session.Close()
 
-	// attatch session to desired database and collection
-	c := session.DB(database).C(groupsCollection)
+	fmt.Println("\n[ OK ] --> Successfully added group: " + newGroup.Name + " to the environment: " + newGroup.Environment + ".\n")
+}
 
-	result := AnsibleGroups{}
-	// executes the query and returns single match
-	err = c.Find(bson.M{"name": groupName}).One(&result)
-	if err != nil {
-		panic(err)
-	}
-
-	uGroup := AnsibleGroups{}
-	uGroup.Members = result.Members
-	uGroup.Description = result.Description
-	uGroup.Environment = result.Environment
-	uGroup.Name = result.Name
-
-	for i, v := range uGroup.Members[groupName] {
-		if v == hostName {
-			gslice := uGroup.Members[groupName]
-			n := i + 1
-			gslice = append(gslice[:i], gslice[n:]...)
-			uGroup.Members[groupName] = gslice
-			break
-		}
+func (c *clerk) cloneHost(templateName, hostName, envName, database string) {
+	fmt.Println("\n[ INFO ] --> Creating New host: " + hostName + " from Template host: " + templateName + "\n")
+	if err := c.inv.CloneHost(templateName, hostName, envName, database); err != nil {
+		fail(err)
 	}
 
-	err = c.Update(bson.M{"name": groupName}, bson.M{"name": uGroup.Name, "members": uGroup.Members, "description": uGroup.Description, "environment": uGroup.Environment})
+	fmt.Println("\n[ OK ] --> Successfully created " + hostName + " from Template: " + templateName + "\n")
+}
 
-	if err != nil {
-		fmt.Println("Failed to Update Group after detach")
-		panic(err)
+func (c *clerk) attachHost(hostName, groupName, envName, database string) {
+	fmt.Println("\nAttaching " + hostName + " to " + groupName + "............\n")
+	if err := c.inv.AttachHost(hostName, groupName, envName, database); err != nil {
+		fail(err)
 	}
+
+	fmt.Println("\n[ OK ] --> Successfully attached " + hostName + " to " + groupName + " \n")
 }
 
-func deleteHost(hostName, hostEnv, database string) {
-	colName := strings.ToLower(strings.Replace(hostEnv, "-", "_", -1)) + "_hosts"
+func (c *clerk) detachHost(hostName, groupName, envName, database string) {
+	if err := c.inv.DetachHost(hostName, groupName, envName, database); err != nil {
+		fail(err)
+	}
+}
 
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
+func (c *clerk) displayHost(hName, hEnv, database, format string) {
+	result, err := c.inv.Host(hName, hEnv, database)
 	if err != nil {
-		panic(err)
+		fail(err)
 	}
-	defer session.Close()
-
-	// attatch session to desired database and collection
-	c := session.DB(database).C(colName)
 
-	result := AnsibleHost{}
-	// executes the query and returns single match
-	err = c.Find(bson.M{"fqdn": hostName}).One(&result)
-	if err != nil {
-		fmt.Println("\n[ WARNING ] The host: " + hostName + " does not exist in " + database + ".\n")
+	if format == JSONOUTPUT || format == YAMLOUTPUT {
+		printOutput(format, newHostOutput(result, database))
 		return
 	}
 
-	for group := range result.Groups {
-		detachHostFromGroup(result.Fqdn, group, ENV, database)
+	fmt.Println("\n--BEGIN--\n|\n=====================   [ Details ]   =====================\n|")
+	fmt.Println("| Hostname: " + result.Fqdn + "\n| Environment: " + result.Environment + "\n|")
+	fmt.Println("| OS Type: " + result.OsType + "\n| OS Version: " + result.OsVersion + "\n| Architecture: " + result.ArchType + "\n|\n|")
+	fmt.Println("|\n=====================   [ Groups ]   ======================\n|")
+	groupNames := make([]string, 0, len(result.Groups))
+	for k := range result.Groups {
+		groupNames = append(groupNames, k)
 	}
-
-	err = c.Remove(bson.M{"fqdn": hostName})
-	if err != nil {
-		panic(err)
+	sort.Strings(groupNames)
+	for _, k := range groupNames {
+		fmt.Println("| " + k)
 	}
-}
+	fmt.Println("|\n=====================   [ Variables ]   ===================\n|")
+	printVars(result.Vars)
+	fmt.Print("|\n|\n|\n--END--\n\n")
 
-func deleteGroup(groupName, envName, database string) {
-
-	// Set up Groups collection reference for the supplied environment
-	colName := strings.ToLower(strings.Replace(envName, "-", "_", -1)) + "_groups"
-	allGroup := strings.ToLower(strings.Replace(envName, "-", "_", -1)) + "_all"
-
-	if groupName == allGroup {
-		if envExists(envName, database) {
-			fmt.Println("\n[ ERROR ] --> Sorry, you cannot delete the group: " + groupName + "because the environment to which this group belongs still exists.\n")
-			fmt.Println("You must delete the following environment: " + envName + "first.\n")
-			os.Exit(1)
-		}
-	}
+}
 
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
+func (c *clerk) displayGroup(gName, gEnv, database, format string) {
+	result, err := c.inv.Group(gName, gEnv, database)
 	if err != nil {
-		panic(err)
+		fail(err)
 	}
-	defer session.Close()
-
-	// attatch session to desired database and collection
-	c := session.DB(database).C(colName)
 
-	result := AnsibleGroups{}
-	// executes the query and returns single match
-	err = c.Find(bson.M{"name": groupName}).One(&result)
-	if err != nil {
-		fmt.Println("\n[ ERROR ] --> Failed to find group: " + groupName + " in " + database + ".\n")
-		os.Exit(1)
+	if format == JSONOUTPUT || format == YAMLOUTPUT {
+		printOutput(format, newGroupOutput(result, database))
+		return
 	}
 
-	for _, v := range result.Members[groupName] {
-		fmt.Println("\n[ INFO ] --> detaching group: " + groupName + " from host: " + v + ".\n")
-		detachGroupFromHost(v, groupName, envName, database)
+	fmt.Println("\n--BEGIN--\n\n=====================   [ Details ]   =====================\n|")
+	fmt.Println("| Groupname: " + result.Name + "\n| Description: " + result.Description + "\n| Environment: " + result.Environment + "\n|\n|")
+	fmt.Println("|\n=====================   [ Hosts ]   ======================\n|")
+	for _, item := range result.Members[gName] {
+		fmt.Println("| " + item)
 	}
-
-	removeGroupFromEnv(groupName, ENV, database)
-
-	err = c.Remove(bson.M{"name": result.Name})
-	if err != nil {
-		fmt.Println("\n[ ERROR ] --> Unable to remove group: " + groupName + " from groups database in " + database + ".\n")
-		os.Exit(1)
+	fmt.Println("|\n=====================   [ Children ]   ===================\n|")
+	for _, item := range result.Children {
+		fmt.Println("| " + item)
 	}
+	fmt.Println("|\n=====================   [ Variables ]   ===================\n|")
+	printVars(result.Vars)
+	fmt.Print("|\n|\n\n--END--\n\n")
 
 }
 
-func removeGroupFromEnv(groupname, environment, database string) {
-	envCollection := "environments"
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
+func (c *clerk) setGroupVars(groupName, envName, database string, varMap map[string]string) {
+	fmt.Println("\n[ INFO ] --> Setting variables on group: " + groupName + " in database: " + database + "......\n")
+	if err := c.inv.SetGroupVars(groupName, envName, database, varMap); err != nil {
+		fail(err)
 	}
-	defer session.Close()
 
-	// attatch session to desired database and collection
-	c := session.DB(database).C(envCollection)
+	fmt.Println("\n[ OK ] --> Successfully set variables on group: " + groupName + "\n")
+}
 
-	ansibleEnv := AnsibleEnvironment{}
-	err = c.Find(bson.M{"name": environment}).One(&ansibleEnv)
-	if err != nil {
-		fmt.Println("\n[ ERROR ] -- The environment: " + environment + " does not exist in database: " + database + ".\n")
-		os.Exit(1)
+func (c *clerk) unsetGroupVars(groupName, envName, database string, keys []string) {
+	fmt.Println("\n[ INFO ] --> Removing variables from group: " + groupName + " in database: " + database + "......\n")
+	if err := c.inv.UnsetGroupVars(groupName, envName, database, keys); err != nil {
+		fail(err)
 	}
 
-	if ansibleEnv.Groups[groupname] {
-		fmt.Println("\n[ INFO ] --> Attempting to remove Group: " + groupname + " from environment: " + environment + ".\n ")
-		delete(ansibleEnv.Groups, groupname)
-
-		// replace existing environment with the updated environment
-		err = c.Update(bson.M{"name": ENV}, bson.M{"name": ENV, "groups": ansibleEnv.Groups, "prefix": ansibleEnv.Prefix})
-
-		if err != nil {
-			fmt.Println("\n[ ERROR ] --> Failed to remove group: " + groupname + " from  Environment: " + ENV + "\n")
-			os.Exit(1)
-		}
-
-		fmt.Println("\n[ OK ] --> Successfully removed group: " + groupname + " from  Environment: " + environment + ".\n")
-	} else {
-		fmt.Println("\n[ WARNING ] --> The group: " + groupname + " was not found in Environment" + environment + "....skipping remove.\n")
-	}
+	fmt.Println("\n[ OK ] --> Successfully removed variables from group: " + groupName + "\n")
 }
 
-func createInventoryFile(envName, database string) {
-
-	fileDirMap := make(map[string]string, 2)
-	fileDirMap["provisioner"] = "/apps/ansible-provisioner-inventories/"
-	fileDirMap["custodian"] = "/apps/ansible-inventories/"
+func (c *clerk) addChildGroup(parentName, childName, envName, database string) {
+	fmt.Println("\n[ INFO ] --> Adding group: " + childName + " as a child of group: " + parentName + " in database: " + database + "......\n")
+	if err := c.inv.AddChildGroup(parentName, childName, envName, database); err != nil {
+		fail(err)
+	}
 
-	envDir := strings.ToLower(strings.Replace(envName, "-", "_", -1))
-	invFile := InventoryFile{}
-	invFile.Path = fileDirMap[database] + envDir + "/" + envDir + ".inventory"
-	invFile.Environment = envName
+	fmt.Println("\n[ OK ] --> Successfully added child group: " + childName + " to group: " + parentName + "\n")
+}
 
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
+func (c *clerk) removeChildGroup(parentName, childName, envName, database string) {
+	fmt.Println("\n[ INFO ] --> Removing child group: " + childName + " from group: " + parentName + " in database: " + database + "......\n")
+	if err := c.inv.RemoveChildGroup(parentName, childName, envName, database); err != nil {
+		fail(err)
 	}
-	defer session.Close()
 
-	// attatch session to desired database and collection
-	c := session.DB(database).C("inventory_files")
+	fmt.Println("\n[ OK ] --> Successfully removed child group: " + childName + " from group: " + parentName + "\n")
+}
 
-	err = c.Insert(&invFile)
+func (c *clerk) listGroupOptions(ansibleEnv, database, format string) {
+	groups, err := c.inv.Groups(ansibleEnv, database)
 	if err != nil {
-		fmt.Println("\n[ ERROR ] -- Failed to add inventory file to inventory files collection in " + database + ".\n")
-		os.Exit(1)
+		fail(err)
 	}
 
-	// create environment inventory file directory
-	inventoryDir := fileDirMap[database] + envDir
-	err = os.Mkdir(inventoryDir, 0644)
-	if err != nil {
-		fmt.Println("\n[ ERROR ] -- Failed to create inventory directory: " + inventoryDir + ".\n")
-		os.Exit(1)
+	if format == JSONOUTPUT || format == YAMLOUTPUT {
+		names := make([]string, 0, len(groups))
+		for _, ansibleGrp := range groups {
+			names = append(names, ansibleGrp.Name)
+		}
+		printOutput(format, names)
+		return
 	}
 
-	// create environment inventory file backup directory
-	backupDir := inventoryDir + "/backups"
-	err = os.Mkdir(backupDir, 0644)
-	if err != nil {
-		fmt.Println("\n[ ERROR ] --> Failed to create inventory backup directory: " + backupDir + " in " + database + ".\n")
-		os.Exit(1)
+	for _, ansibleGrp := range groups {
+		// Print out each group name
+		fmt.Println(ansibleGrp.Name)
 	}
+}
 
-	// create file header
-	fileHeader := "# -- !!! WARNING !!! -- This File is managed by provisioner, any changes will be over-written\n # on the next provisioner run.\n#\n#\n"
-
-	// create the physical inventory file
-	f, err := os.Create(invFile.Path)
-
+func (c *clerk) listHostOptions(ansibleEnv, database string, filter inventory.HostFilter, format string) {
+	hosts, err := c.inv.Hosts(ansibleEnv, database, filter)
 	if err != nil {
-		fmt.Println("\n[ ERROR ] --> Failed to create inventory file: " + invFile.Path + " in " + database + ".\n")
-		os.Exit(1)
+		fail(err)
 	}
 
-	// ensure inventory file handle is released
-	defer f.Close()
+	if format == JSONOUTPUT || format == YAMLOUTPUT {
+		result := make([]hostOutput, 0, len(hosts))
+		for _, ansibleHost := range hosts {
+			result = append(result, newHostOutput(ansibleHost, database))
+		}
+		printOutput(format, result)
+		return
+	}
 
-	// write file header out to inventory file
-	_, err = f.WriteString(fileHeader)
-	if err != nil {
-		fmt.Println("\n[ ERROR ] --> Falure writing header to inventory file: " + invFile.Path + " in " + database + ".\n")
-		os.Exit(1)
+	for _, ansibleHost := range hosts {
+		// Print out each host name
+		fmt.Println(ansibleHost.Fqdn)
 	}
 
-	f.Sync()
 }
 
-func updateInventoryFile(envName, database string) {
-
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
+func (c *clerk) deleteHost(hostName, hostEnv, database string) {
+	if err := c.inv.DeleteHost(hostName, hostEnv, database); err != nil {
+		fail(err)
 	}
-	defer session.Close()
-
-	// attatch session to desired database and collection
-	c := session.DB(database).C("inventory_files")
+}
 
-	resultFile := InventoryFile{}
-	// executes the query and returns single match
-	err = c.Find(bson.M{"environment": envName}).One(&resultFile)
-	if err != nil {
-		fmt.Println("\n[ ERROR ] --> The environemnt: " + ENV + " could not be found in database: " + database + ".\n")
-		os.Exit(1)
-	}
-
-	envDir := strings.ToLower(strings.Replace(envName, "-", "_", -1))
-	//timeStampSlice := strings.Fields(time.Now().String())
-	//timeStamp := timeStampSlice[0]
-	timeStamp := strconv.FormatInt(time.Now().Unix(), 10)
-	filePath := resultFile.Path
-	backupPath := "/apps/ansible-provisioner-inventories/" + envDir + "/backups/" + envDir + ".inventory." + timeStamp
-	// backup the file
-	err = os.Rename(filePath, backupPath)
-	if err != nil {
-		panic(err)
+func (c *clerk) deleteGroup(groupName, envName, database string) {
+	err := c.inv.DeleteGroup(groupName, envName, database)
+	if errors.Is(err, inventory.ErrInUse) {
+		exit(EXITCONFLICT, "ERROR", "Sorry, you cannot delete the group: "+groupName+" because the environment to which this group belongs still exists.\n\nYou must delete the following environment: "+envName+" first.")
 	}
-
-	//Set up groups collection
-	gCollection := strings.ToLower(strings.Replace(envName, "-", "_", -1)) + "_groups"
-	groupSession, err := mgo.Dial(MONGOIP)
 	if err != nil {
-		panic(err)
+		fail(err)
 	}
-	defer groupSession.Close()
-	gC := groupSession.DB(database).C(gCollection)
-
-	fileHeader := "# -- !!! WARNING !!! -- This File is managed by provisioner, any changes will be over-written\n# on the next provisioner run.\n#\n#\n"
-
-	// create new inventory file
-	f, err := os.Create(filePath)
+}
 
-	if err != nil {
-		fmt.Println("\n[ ERROR ] --> Failed to create inventory file for environment: " + ENV + " in database: " + database + ".\n")
-		os.Exit(1)
+func (c *clerk) createInventoryFile(envName, database string) {
+	if err := c.inv.CreateInventoryFile(envName, database); err != nil {
+		fail(err)
 	}
+}
 
-	// ensure inventory file handle is released
-	defer f.Close()
-
-	_, err = f.WriteString(fileHeader)
-
-	// get Iterator of items in the collection
-	iter := gC.Find(nil).Iter()
-	var ansibleGrps AnsibleGroups
-	//groupsSlice := make(map[string][]string)
-	for iter.Next(&ansibleGrps) {
-		// write Group Description as Comment
-		_, err = f.WriteString("# " + ansibleGrps.Description + "\n")
-		if err != nil {
-			panic(err)
-		}
-
-		_, err = f.WriteString("[" + ansibleGrps.Name + "]\n")
+func (c *clerk) updateInventoryFile(envName, database string) {
+	if CONFIG.Inventory.LogChanges {
+		diff, err := c.inv.DiffInventoryFile(envName, database, "")
 		if err != nil {
-			panic(err)
-		}
-
-		for k := range ansibleGrps.Members[ansibleGrps.Name] {
-			_, err = f.WriteString(ansibleGrps.Members[ansibleGrps.Name][k] + "\n")
-			if err != nil {
-				panic(err)
-			}
+			fail(err)
 		}
-
-		_, err = f.WriteString("\n\n\n")
-		if err != nil {
-			panic(err)
+		if !diff.Changes.Empty() {
+			fmt.Println("\n[ INFO ] --> Changes to the Inventory file for Environment: " + envName + " in " + database + ":\n")
+			printInventoryChanges(diff.Changes)
 		}
 	}
 
-	f.Sync()
-
+	if err := c.inv.UpdateInventoryFile(envName, database); err != nil {
+		fail(err)
+	}
 }
 
-// Host validation function
-func hostExists(hostName, envName, database string) bool {
-
-	//Set up hosts collection reference
-	hCollection := strings.ToLower(strings.Replace(envName, "-", "_", -1)) + "_hosts"
-
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		panic(err)
+// prints the variables of a host or group sorted by name
+func printVars(varMap map[string]string) {
+	names := make([]string, 0, len(varMap))
+	for k := range varMap {
+		names = append(names, k)
 	}
-	defer session.Close()
-
-	// attatch session to desired database and collection
-	c := session.DB(database).C(hCollection)
-
-	doesExist := false
-
-	// get Iterator of items in the collection
-	iter := c.Find(nil).Iter()
-	var ansibleHost AnsibleHost
-	for iter.Next(&ansibleHost) {
-		if ansibleHost.Fqdn == hostName {
-			doesExist = true
-		}
+	sort.Strings(names)
+	for _, k := range names {
+		fmt.Println("| " + k + " = " + varMap[k])
 	}
-
-	return doesExist
-
 }
 
-// Group validation function
-func groupExists(groupName, envName, database string) bool {
-
-	//Set up groups collection
-	gCollection := strings.ToLower(strings.Replace(envName, "-", "_", -1)) + "_groups"
-
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
+func (c *clerk) listInventoryBackups(envName, database, format string) {
+	backups, err := c.inv.InventoryBackups(envName, database)
 	if err != nil {
-		panic(err)
+		fail(err)
 	}
-	defer session.Close()
-
-	// attatch session to desired database and collection
-	c := session.DB(database).C(gCollection)
 
-	doesExist := false
-
-	// get Iterator of items in the collection
-	iter := c.Find(nil).Iter()
-	var ansibleGrp AnsibleGroups
-	for iter.Next(&ansibleGrp) {
-		if ansibleGrp.Name == groupName {
-			doesExist = true
+	if format == JSONOUTPUT || format == YAMLOUTPUT {
+		result := make([]backupOutput, 0, len(backups))
+		for _, backup := range backups {
+			result = append(result, backupOutput{Timestamp: backup.Timestamp, Time: backup.Time, Path: backup.Path})
 		}
+		printOutput(format, result)
+		return
 	}
 
-	return doesExist
-
+	for _, backup := range backups {
+		fmt.Println(backup.Timestamp + "  " + backup.Time.Local().Format("2006-01-02 15:04:05"))
+	}
 }
 
-// Environment validation function
-func envExists(environment, database string) bool {
-
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
+// prints how the inventory file of an environment differs from the datastore, as a unified
+// diff or as a summary of the hosts and groups that differ
+func (c *clerk) diffInventoryFile(envName, database, timestamp string, summary bool, format string) {
+	diff, err := c.inv.DiffInventoryFile(envName, database, timestamp)
 	if err != nil {
-		panic(err)
+		fail(err)
 	}
-	defer session.Close()
 
-	// attatch session to desired database and collection
-	c := session.DB(database).C("environments")
-
-	doesExist := false
+	if format == JSONOUTPUT || format == YAMLOUTPUT {
+		printOutput(format, newInventoryDiffOutput(diff))
+		return
+	}
 
-	// get Iterator of items in the collection
-	iter := c.Find(nil).Iter()
-	var ansibleEnv AnsibleEnvironment
-	for iter.Next(&ansibleEnv) {
-		if ansibleEnv.Name == environment {
-			doesExist = true
-		}
+	if diff.Changes.Empty() && diff.Unified == "" {
+		fmt.Print("\n[ INFO ] --> The Inventory file " + diff.From + " is up to date with the datastore.\n\n")
+		return
 	}
 
-	return doesExist
+	if !summary {
+		fmt.Print(diff.Unified)
+		return
+	}
+	fmt.Println("--- " + diff.From)
+	fmt.Println("+++ " + diff.To)
+	printInventoryChanges(diff.Changes)
 }
 
-// pull a single host out of custodian database and put it in the provisioner database
-func pullOneHost(host string) {
-
-	provDB := "provisioner"
-	custDB := "custodian"
-
-	hostCollection := strings.ToLower(strings.Replace(ENV, "-", "_", -1)) + "_hosts"
-	groupCollection := strings.ToLower(strings.Replace(ENV, "-", "_", -1)) + "_groups"
-
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
-	if err != nil {
-		fmt.Println("\n[ FAILED ] --> Unable to obtain a connection to MongoDB.\n")
-		os.Exit(1)
+// prints the hosts and groups that change in an inventory file, one change per line
+func printInventoryChanges(changes inventory.InventoryChanges) {
+	for _, name := range changes.AddedGroups {
+		fmt.Println("added group: " + name)
 	}
-	defer session.Close()
-
-	// attatch session to desired database and collection
-	c1 := session.DB(custDB).C(hostCollection)
-
-	custHost := AnsibleHost{}
-	// executes the query and returns single match
-	err = c1.Find(bson.M{"fqdn": host}).One(&custHost)
-	if err != nil {
-		fmt.Println("\n[ FAILED ] --> The host: " + host + " does not exist in the custodian database.\n")
-		os.Exit(1)
+	for _, name := range changes.RemovedGroups {
+		fmt.Println("removed group: " + name)
 	}
-
-	// copy host into provisioner database
-	c2 := session.DB(provDB).C(hostCollection)
-
-	fmt.Println("\nAdding " + custHost.Fqdn + " to Provisioner Environment: " + ENV + "......\n")
-	err = c2.Insert(&custHost)
-	if err != nil {
-		fmt.Println("\n[ ERROR ] --> Failed to add host: " + host + " to provisioner database.\n")
-		os.Exit(1)
+	for _, name := range changes.ChangedGroups {
+		fmt.Println("changed children or variables of group: " + name)
 	}
-
-	// attach session to provisioner groups collection to attach host to the appropriate groups
-	c3 := session.DB(provDB).C(groupCollection)
-
-	for group := range custHost.Groups {
-		// ensure that a provisioner group exists for each group that exists in custodian
-
-		provGroup := AnsibleGroups{}
-		// executes the query and returns single match
-		err = c3.Find(bson.M{"name": group}).One(&provGroup)
-		if err != nil {
-			fmt.Println("\n[ INFO ] --> Failed to locate local provisioner group named: " + group + "... validating group.\n")
-			custGroup := AnsibleGroups{}
-			c4 := session.DB(custDB).C(groupCollection)
-			err = c4.Find(bson.M{"name": group}).One(&custGroup)
-			if err != nil {
-				fmt.Println("\n[ ERROR ] --> Group: " + group + " is missing from custodian but is referenced in host: " + host + ".\n")
-				fmt.Println("[ ERROR ] --> Something is out of sync between custodian and provisioner\n")
-				fmt.Println("[ FATAL ERROR ] --> Manual Intervention is required.\n")
-				os.Exit(1)
-			}
-			fmt.Println("\n[ INFO ] --> Successfully validated group: " + group + " in custodian...continuing with add.\n")
-
-			// add group to database
-			provGroup := AnsibleGroups{}
-			provGroup.Members = map[string][]string{group: make([]string, 0)}
-			provGroup.Description = custGroup.Description
-			provGroup.Environment = custGroup.Environment
-			provGroup.Name = custGroup.Name
-			fmt.Println("\n[ INFO ] --> Adding group: " + provGroup.Name + " to the provisioner database.\n")
-			c5 := session.DB(provDB).C(groupCollection)
-			err = c5.Insert(&provGroup)
-
-			// check for errors
-			if err != nil {
-				fmt.Println("\n[ ERROR ] --> Failed to add group: " + group + " to provisioner database.\n")
-				os.Exit(1)
-			}
-			// attach the group the corresponding provisioner environment
-			fmt.Println("\n[ INFO ] --> Adding Group " + group + " to the Provisioner Inventory Environment " + ENV + "......\n")
-			assocGroupToEnv(group, ENV, "provisioner")
-			fmt.Println("\n[ OK ] -- Successfully added group to " + ENV + "\n")
-		}
-
-		//add member to updated group -- added to members group slice
-		isPresent := false
-		for member := range provGroup.Members[group] {
-			if provGroup.Members[group][member] == host {
-				isPresent = true
-			}
-		}
-
-		if !isPresent {
-			provGroup.Members[group] = append(provGroup.Members[group], host)
-		} else {
-			fmt.Println("\n[ INFO ] --> The host: " + host + " already exists in group: " + provGroup.Name + "... skipping add.\n")
-		}
-
-		c6 := session.DB(provDB).C(groupCollection)
-		err = c6.Update(bson.M{"name": provGroup.Name}, bson.M{"name": provGroup.Name, "members": provGroup.Members, "description": provGroup.Description, "environment": provGroup.Environment})
-
-		if err != nil {
-			fmt.Println("\n[ ERROR ] --> Failed to Update Group: " + group + " with new host: " + host + ".\n")
-			os.Exit(1)
-		}
+	for _, name := range changes.AddedHosts {
+		fmt.Println("added host: " + name)
 	}
-
-	// delete host from custodian database
-	// attatch session to desired database and collection
-	c7 := session.DB(custDB).C(hostCollection)
-
-	delHost := AnsibleHost{}
-	// executes the query and returns single match
-	err = c7.Find(bson.M{"fqdn": host}).One(&delHost)
-	if err != nil {
-		fmt.Println("The host: " + host + " does not exist in custodian and therefore will not be deleted.\n")
+	for _, name := range changes.RemovedHosts {
+		fmt.Println("removed host: " + name)
 	}
 
-	for group := range delHost.Groups {
-
-		// attatch session to desired database and collection
-		c8 := session.DB(custDB).C(groupCollection)
-
-		resultGroup := AnsibleGroups{}
-		// executes the query and returns single match
-		err = c8.Find(bson.M{"name": group}).One(&resultGroup)
-		if err != nil {
-			fmt.Println("\n[ ERROR ] --> Could not find group: " + group + " in custodian database when it should be present.\n")
-			os.Exit(1)
-		}
-
-		for i, v := range resultGroup.Members[group] {
-			if v == host {
-				gslice := resultGroup.Members[group]
-				n := i + 1
-				gslice = append(gslice[:i], gslice[n:]...)
-				resultGroup.Members[group] = gslice
-				break
-			}
-		}
-
-		err = c8.Update(bson.M{"name": group}, bson.M{"name": resultGroup.Name, "members": resultGroup.Members, "description": resultGroup.Description, "environment": resultGroup.Environment})
-		if err != nil {
-			fmt.Println("\n[ ERROR ] --> Failed to remove host: " + host + " from group: " + group + " in custodian database.\n")
-			os.Exit(1)
+	groupNames := []string{}
+	for name := range changes.AddedMembers {
+		groupNames = append(groupNames, name)
+	}
+	for name := range changes.RemovedMembers {
+		if _, ok := changes.AddedMembers[name]; !ok {
+			groupNames = append(groupNames, name)
 		}
-
 	}
-
-	// Delete the host from the custodian database
-	c9 := session.DB(custDB).C(hostCollection)
-	err = c9.Remove(bson.M{"fqdn": host})
-	if err != nil {
-		fmt.Println("[ ERROR ] --> Failed to remove host from custodian database.\n")
-		os.Exit(1)
+	sort.Strings(groupNames)
+	for _, name := range groupNames {
+		fmt.Println("members of group " + name + ": " + nameDiff(changes.RemovedMembers[name], changes.AddedMembers[name]))
 	}
-
 }
 
-// push hosts from provisioner database into custodian database
-func pushOneHost(host string) {
-
-	provDB := "provisioner"
-	custDB := "custodian"
-
-	hostCollection := strings.ToLower(strings.Replace(ENV, "-", "_", -1)) + "_hosts"
-	groupCollection := strings.ToLower(strings.Replace(ENV, "-", "_", -1)) + "_groups"
+func (c *clerk) rollbackInventoryFile(envName, database, timestamp string) {
+	fmt.Println("\n[ INFO ] --> Rolling back the Inventory file for Environment: " + envName + " in " + database + " to the backup: " + timestamp + "...............\n")
+	if err := c.inv.RollbackInventoryFile(envName, database, timestamp); err != nil {
+		fail(err)
+	}
+	fmt.Println("\n[ OK ] --> Successfully rolled back the Inventory File for " + envName + " in " + database + ". The next change to the environment writes it from the datastore again.\n")
+}
 
-	// Set up connection to database server
-	session, err := mgo.Dial(MONGOIP)
+// Host validation function
+func (c *clerk) hostExists(hostName, envName, database string) bool {
+	exists, err := c.inv.HostExists(hostName, envName, database)
 	if err != nil {
-		fmt.Println("\n[ FAILED ] --> Unable to obtain a connection to MongoDB.\n")
-		os.Exit(1)
+		fail(err)
 	}
-	defer session.Close()
 
-	// attatch session to desired database and collection
-	c1 := session.DB(provDB).C(hostCollection)
+	return exists
+}
 
-	provHost := AnsibleHost{}
-	// executes the query and returns single match
-	err = c1.Find(bson.M{"fqdn": host}).One(&provHost)
+// Group validation function
+func (c *clerk) groupExists(groupName, envName, database string) bool {
+	exists, err := c.inv.GroupExists(groupName, envName, database)
 	if err != nil {
-		fmt.Println("\n[ FAILED ] --> The host: " + host + " does not exist in the provisioner database.\n")
-		os.Exit(1)
+		fail(err)
 	}
 
-	// copy host into custodian database
-	c2 := session.DB(custDB).C(hostCollection)
+	return exists
+}
 
-	fmt.Println("\nAdding " + provHost.Fqdn + " to custodian Environment: " + ENV + "......\n")
-	err = c2.Insert(&provHost)
+// Environment validation function
+func (c *clerk) envExists(environment, database string) bool {
+	exists, err := c.inv.EnvExists(environment, database)
 	if err != nil {
-		fmt.Println("\n[ ERROR ] --> Failed to add host: " + host + " to provisioner database.\n")
-		os.Exit(1)
+		fail(err)
 	}
 
-	// attach session to custodian groups collection to attach host to the appropriate groups
-	c3 := session.DB(custDB).C(groupCollection)
+	return exists
+}
 
-	for group := range provHost.Groups {
-		// ensure that a custodian group exists for each group that exists in provisioner
+// pull a single host out of custodian database and put it in the provisioner database
+func (c *clerk) pullOneHost(host string) {
 
-		custGroup := AnsibleGroups{}
-		// executes the query and returns single match
-		err = c3.Find(bson.M{"name": group}).One(&custGroup)
-		if err != nil {
-			fmt.Println("\n[ INFO ] --> Failed to locate local custodian group named: " + group + "... validating group.\n")
-			provGroup := AnsibleGroups{}
-			c4 := session.DB(provDB).C(groupCollection)
-			err = c4.Find(bson.M{"name": group}).One(&provGroup)
-			if err != nil {
-				fmt.Println("\n[ ERROR ] --> Group: " + group + " is missing from provisioner but is referenced in host: " + host + ".\n")
-				fmt.Println("[ ERROR ] --> Something is out of sync between custodian and provisioner\n")
-				fmt.Println("[ FATAL ERROR ] --> Manual Intervention is required.\n")
-				os.Exit(1)
-			}
-			fmt.Println("\n[ OK ] --> Successfully validated group: " + group + " in provisioner...continuing with add.\n")
-
-			// add group to custodian database
-			custGroup = provGroup
-			custGroup.Members = make(map[string][]string, 0)
-			custGroup.Members[group] = make([]string, 0)
-
-			fmt.Println("\n[ INFO ] --> Adding group: " + custGroup.Name + " to the custodian database.\n")
-			c5 := session.DB(custDB).C(groupCollection)
-			err = c5.Insert(&custGroup)
-
-			// check for errors
-			if err != nil {
-				fmt.Println("\n[ ERROR ] --> Failed to add group: " + group + " to custodian database.\n")
-				os.Exit(1)
-			}
-			// attach the group the corresponding custodian environment
-			fmt.Println("\n[ INFO ] --> Adding Group " + group + " to the Custodian Inventory Environment " + ENV + "......\n")
-
-			//NEED TO ACTUALLY ADD THE CODE HERE FOR OTHER DB GROUP-ENV ASSOCIATION -- Associate group to env in custodian
-			c6 := session.DB(custDB).C("environments")
-			custEnv := AnsibleEnvironment{}
-			c6.Find(bson.M{"name": ENV}).One(&custEnv)
-			if !custEnv.Groups[group] {
-
-				// update the Groups Map to include the new Group if not already there
-
-				custEnv.Groups[group] = true
-
-				// replace existing environment with the updated environment
-				err = c6.Update(bson.M{"name": ENV}, bson.M{"name": ENV, "groups": custEnv.Groups, "prefix": custEnv.Prefix})
-
-				if err != nil {
-					fmt.Println("Failed to update Environment: " + ENV + "\n")
-					os.Exit(1)
-				}
-
-				fmt.Println("\n[ OK ] --> Successfully updated Environment: " + ENV + "\n")
-
-			}
-			fmt.Println("\n[ OK ] -- Successfully added group to " + ENV + "\n")
-		}
-		//add member to updated group -- added to members group slice
-		isPresent := false
-		for member := range custGroup.Members[group] {
-			if custGroup.Members[group][member] == host {
-				isPresent = true
-			}
-		}
+	fmt.Println("\nAdding " + host + " to Provisioner Environment: " + ENV + "......\n")
+	err := c.inv.MoveHost(host, ENV, inventory.CUSTODIAN, inventory.PROVISIONER)
+	if err != nil {
+		failMove("Failed to pull host: "+host+" from custodian database", err)
+	}
 
-		if !isPresent {
-			fmt.Println("\n[ INFO ] --> adding host: " + host + " to group: " + group + ".\n")
-			custGroup.Members[group] = append(custGroup.Members[group], host)
-		} else {
-			fmt.Println("\n[ INFO ] --> The host: " + host + " already exists in group: " + custGroup.Name + "... skipping add.\n")
-		}
+	fmt.Println("\n[ OK ] --> Successfully pulled host: " + host + " into provisioner database.\n")
 
-		c7 := session.DB(custDB).C(groupCollection)
-		err = c7.Update(bson.M{"name": custGroup.Name}, bson.M{"name": custGroup.Name, "members": custGroup.Members, "description": custGroup.Description, "environment": custGroup.Environment})
+	c.updateMovedInventoryFiles(ENV)
 
-		if err != nil {
-			fmt.Println("\n[ ERROR ] --> Failed to Update Group: " + group + " with new host: " + host + ".\n")
-			os.Exit(1)
-		}
-	}
+}
 
-	// delete host from provisioner database
-	// attatch session to desired database and collection
-	c8 := session.DB(provDB).C(hostCollection)
+// push hosts from provisioner database into custodian database
+func (c *clerk) pushOneHost(host string) {
 
-	delHost := AnsibleHost{}
-	// executes the query and returns single match
-	err = c8.Find(bson.M{"fqdn": host}).One(&delHost)
+	fmt.Println("\nAdding " + host + " to custodian Environment: " + ENV + "......\n")
+	err := c.inv.MoveHost(host, ENV, inventory.PROVISIONER, inventory.CUSTODIAN)
 	if err != nil {
-		fmt.Println("The host: " + host + " does not exist in provisioner and therefore will not be deleted.\n")
+		failMove("Failed to push host: "+host+" to custodian database", err)
 	}
 
-	for group := range delHost.Groups {
+	fmt.Println("\n[ OK ] --> Successfully pushed host: " + host + " into custodian database.\n")
 
-		// attatch session to desired database and collection
-		c9 := session.DB(provDB).C(groupCollection)
+	c.updateMovedInventoryFiles(ENV)
 
-		resultGroup := AnsibleGroups{}
-		// executes the query and returns single match
-		err = c9.Find(bson.M{"name": group}).One(&resultGroup)
-		if err != nil {
-			fmt.Println("\n[ ERROR ] --> Could not find group: " + group + " in provisioner database when it should be present.\n")
-			os.Exit(1)
-		}
+}
 
-		// MAY NEED ADDITIONAL BOUNDS CHECKING FOR WHEN MEMBERS SLICE LENGTH IS LESS THAN OR EQUAL TO 1
-		for i, v := range resultGroup.Members[group] {
-			if v == host {
-				gslice := resultGroup.Members[group]
-				n := i + 1
-				gslice = append(gslice[:i], gslice[n:]...)
-				resultGroup.Members[group] = gslice
-				break
-			}
-		}
+// update the inventory files of an environment in both datastores after a host moved between them
+func (c *clerk) updateMovedInventoryFiles(envName string) {
+	for _, database := range []string{inventory.PROVISIONER, inventory.CUSTODIAN} {
+		fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + envName + " in " + database + "...............\n")
+		c.updateInventoryFile(envName, database)
+		fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + envName + " in " + database + ".\n")
+	}
+}
 
-		err = c9.Update(bson.M{"name": group}, bson.M{"name": resultGroup.Name, "members": resultGroup.Members, "description": resultGroup.Description, "environment": resultGroup.Environment})
-		if err != nil {
-			fmt.Println("\n[ ERROR ] --> Failed to remove host: " + host + " from group: " + group + " in provisioner database.\n")
-			os.Exit(1)
-		}
+// prints the error of a failed push or pull and exits, pointing at clerk resume when the
+// move was left unfinished
+func failMove(message string, err error) {
+	if errors.Is(err, inventory.ErrUnfinished) {
+		message += ": " + err.Error() + ".\n\n[ INFO ] --> Run clerk resume to finish or roll back the move."
+	} else {
+		message += ": " + err.Error() + "."
+	}
+	exit(exitCode(err), "ERROR", message)
+}
 
+// finish or roll back the moves left unfinished by interrupted pushes and pulls
+func (c *clerk) resumeMoves() {
+	ops, err := c.inv.ResumeMoves()
+	// the moves that were resumed changed both datastores, even when a later one failed
+	for _, op := range ops {
+		fmt.Println("\n[ OK ] --> Resumed move of host: " + op.Host + " from " + op.From + " to " + op.To + " in Environment: " + op.Environment + ", the move was " + op.State + ".\n")
+		c.updateMovedInventoryFiles(op.Environment)
 	}
 
-	// Delete the host from the provisioner database
-	c10 := session.DB(provDB).C(hostCollection)
-	err = c10.Remove(bson.M{"fqdn": host})
 	if err != nil {
-		fmt.Println("[ ERROR ] --> Failed to remove host from provisioner database.\n")
-		os.Exit(1)
+		exit(exitCode(err), "ERROR", "Failed to resume interrupted moves: "+err.Error()+".")
 	}
 
-	fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in provisioner...............\n")
-	// Update Inventory File
-	updateInventoryFile(ENV, "provisioner")
-	fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in provisioner.\n")
-
-	fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in custodian...............\n")
-	// Update Inventory File
-	updateInventoryFile(ENV, "custodian")
-	fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in custodian.\n")
-
+	if len(ops) == 0 {
+		fmt.Print("\n[ INFO ] --> There are no interrupted moves to resume.\n\n")
+	}
 }
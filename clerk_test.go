@@ -0,0 +1,78 @@
+package main
+
+import (
+	"github.com/raiderops/capernicus/inventory"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// returns a clerk on a bolt database and inventory roots in a temporary directory, with the dev
+// environment and its inventory file in both datastores
+func newTestClerk(t *testing.T) (*clerk, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	config := "backend: bolt\nbolt:\n  path: " + dir + "/clerk.db\ndatastores:\n" +
+		"  provisioner:\n    inventory_root: " + dir + "/provisioner/\n" +
+		"  custodian:\n    inventory_root: " + dir + "/custodian/\n"
+	path := filepath.Join(dir, "clerk.yml")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := inventory.LoadConfig(path)
+	if err != nil {
+		t.Fatalf("loading configuration: %v", err)
+	}
+	store, err := inventory.Open(cfg)
+	if err != nil {
+		t.Fatalf("opening store: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	CONFIG, ENV = cfg, "dev"
+	for _, ds := range []string{inventory.PROVISIONER, inventory.CUSTODIAN} {
+		if err = os.Mkdir(cfg.InventoryRoot(ds), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = store.AddEnvironment(&inventory.AnsibleEnvironment{Name: ENV}, ds); err != nil {
+			t.Fatalf("adding environment to %s: %v", ds, err)
+		}
+		if err = store.CreateInventoryFile(ENV, ds); err != nil {
+			t.Fatalf("creating inventory file in %s: %v", ds, err)
+		}
+	}
+
+	return &clerk{inv: store}, dir
+}
+
+// reports whether the inventory file of the dev environment in a datastore lists a host
+func fileListsHost(t *testing.T, dir, datastore, host string) bool {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(dir, datastore, "dev", "dev.inventory"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Contains(string(content), "\n"+host+"\n")
+}
+
+func TestMoveUpdatesInventoryFiles(t *testing.T) {
+	c, dir := newTestClerk(t)
+	host := "web01.example.com"
+	if err := c.inv.AddHost(inventory.AnsibleHost{Fqdn: host, Environment: ENV}, inventory.CUSTODIAN); err != nil {
+		t.Fatal(err)
+	}
+	c.updateInventoryFile(ENV, inventory.CUSTODIAN)
+
+	c.pullOneHost(host)
+	if !fileListsHost(t, dir, inventory.PROVISIONER, host) || fileListsHost(t, dir, inventory.CUSTODIAN, host) {
+		t.Errorf("after pull, want %s listed in the provisioner inventory file only", host)
+	}
+
+	c.pushOneHost(host)
+	if fileListsHost(t, dir, inventory.PROVISIONER, host) || !fileListsHost(t, dir, inventory.CUSTODIAN, host) {
+		t.Errorf("after push, want %s listed in the custodian inventory file only", host)
+	}
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/raiderops/capernicus/inventory"
+	"golang.org/x/term"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// command is a node of the clerk command tree: either a group of subcommands such as
+// "host", or a runnable command such as "host add" with its own flags
+type command struct {
+	name     string
+	summary  string
+	commands []*command
+
+	// names of the option flags the command takes, see optionFlags, and the ones it requires
+	flags    []string
+	required []string
+
+	// the option flag whose value may also be given as the one argument of the command
+	arg string
+
+	// the command accepts -datastore all to run against every datastore
+	allDatastores bool
+
+	// the command changes the datastores or inventory files, and takes -dry-run
+	mutates bool
+
+	run func(c *clerk, o *options)
+}
+
+// options holds the flag values of a command run
+type options struct {
+	config      string
+	environment string
+	interactive bool
+	dryRun      bool
+
+	datastore   string
+	fqdn        string
+	groups      string
+	group       string
+	child       string
+	description string
+	template    string
+	clone       string
+	hosts       string
+	vars        string
+	toGroup     string
+	fromGroup   string
+	osType      string
+	osVersion   string
+	archType    string
+	output      string
+	user        string
+	since       string
+	until       string
+	timestamp   string
+	show        string
+
+	// the datastores resolved from -datastore, every datastore for -datastore all
+	datastores []string
+}
+
+// optionFlag describes a flag shared by every command that takes it, so that the same
+// flag has the same name, help text and prompt everywhere
+type optionFlag struct {
+	usage  string
+	prompt string
+	value  func(o *options) *string
+}
+
+var optionFlags = map[string]optionFlag{
+	"datastore":   {"Datastore to run against (provisioner|custodian)", "Enter the datastore (provisioner|custodian)", func(o *options) *string { return &o.datastore }},
+	"fqdn":        {"Fully Qualified Domain Name of the host", "Enter the FQDN of the host", func(o *options) *string { return &o.fqdn }},
+	"groups":      {"A comma delimited list of Ansible groups or a single group", "Enter the groups (comma delimited)", func(o *options) *string { return &o.groups }},
+	"group":       {"The name of an Ansible group", "Enter the name of the group", func(o *options) *string { return &o.group }},
+	"child":       {"The name of an Ansible group to nest under -group", "Enter the name of the child group", func(o *options) *string { return &o.child }},
+	"description": {"A short description of the Ansible group", "Enter a short group description", func(o *options) *string { return &o.description }},
+	"template":    {"FQDN of the host to use as a template", "Enter the FQDN of the host to use as a template", func(o *options) *string { return &o.template }},
+	"clone":       {"FQDN of the host to create from the template", "Enter the FQDN of the host you wish to add", func(o *options) *string { return &o.clone }},
+	"hosts":       {"A comma delimited list of hostnames or a single hostname", "Enter the hosts (comma delimited)", func(o *options) *string { return &o.hosts }},
+	"vars":        {"A comma delimited list of key=value variables (or bare keys when unsetting)", "Enter the variables", func(o *options) *string { return &o.vars }},
+	"to-group":    {"The group the host is moved to", "Enter the group to move the host to", func(o *options) *string { return &o.toGroup }},
+	"from-group":  {"The group the host is moved from", "Enter the group to move the host from", func(o *options) *string { return &o.fromGroup }},
+	"osType":      {"Operating System Type (e.g, CentOS|RedHat)", "Enter the type of operating system of the host (RedHat|CentOS)", func(o *options) *string { return &o.osType }},
+	"osVersion":   {"Operating System Version (e.g, 7.0)", "Enter the version of operating system of the host (e.g, 7.0)", func(o *options) *string { return &o.osVersion }},
+	"archType":    {"Machine Architecture Type (e.g, x86_64)", "Enter the machine architecture of the host (e.g, x86_64)", func(o *options) *string { return &o.archType }},
+	"output":      {"Output format: table, json or yaml (default table)", "Enter the output format", func(o *options) *string { return &o.output }},
+	"user":        {"The user who ran the commands", "Enter the user", func(o *options) *string { return &o.user }},
+	"since":       {"Start of the time range: a date (2006-01-02), a time (RFC 3339) or a duration ago (e.g, 24h)", "Enter the start of the time range", func(o *options) *string { return &o.since }},
+	"show":        {"What to show: diff (default) or summary", "Enter what to show (diff|summary)", func(o *options) *string { return &o.show }},
+	"timestamp":   {"Timestamp of an inventory file backup, as listed by clerk inventory backups", "Enter the timestamp of the backup", func(o *options) *string { return &o.timestamp }},
+	"until":       {"End of the time range, in the same forms as -since", "Enter the end of the time range", func(o *options) *string { return &o.until }},
+}
+
+// switches of the flag driven and interactive modes of earlier releases, mapped to the commands
+// that replaced them. The interactive ones prompted for their values.
+var legacySwitches = map[string]struct {
+	path        string
+	interactive bool
+}{
+	"addHost":        {"host add", false},
+	"deleteHost":     {"host delete", false},
+	"attachHost":     {"host attach", false},
+	"detachHost":     {"host detach", false},
+	"moveHost":       {"host move", false},
+	"cloneHost":      {"host clone", false},
+	"hostDetails":    {"host show", false},
+	"hostOptions":    {"host list", false},
+	"setHostVars":    {"host set-vars", false},
+	"unsetHostVars":  {"host unset-vars", false},
+	"addGroup":       {"group add", false},
+	"deleteGroup":    {"group delete", false},
+	"groupDetails":   {"group show", false},
+	"listGroups":     {"group list", false},
+	"groupOptions":   {"group names", false},
+	"setGroupVars":   {"group set-vars", false},
+	"unsetGroupVars": {"group unset-vars", false},
+	"addChild":       {"group add-child", false},
+	"removeChild":    {"group remove-child", false},
+	"addEnvironment": {"env create", false},
+	"push":           {"push", false},
+	"pull":           {"pull", false},
+	"resume":         {"resume", false},
+	"add-host":       {"host add", true},
+	"delete-host":    {"host delete", true},
+	"attach-host":    {"host attach", true},
+	"detach-host":    {"host detach", true},
+	"clone-host":     {"host clone", true},
+	"display-host":   {"host show", true},
+	"host-options":   {"host list", false},
+	"add-group":      {"group add", true},
+	"delete-group":   {"group delete", true},
+	"list-groups":    {"group list", false},
+	"group-options":  {"group names", false},
+	"add-env":        {"env create", true},
+}
+
+// translates a command line of an earlier release into the command that replaced its switch,
+// returning the arguments unchanged when they do not use one
+func translateLegacy(args []string) []string {
+	for i, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		legacy, ok := legacySwitches[strings.TrimLeft(arg, "-")]
+		if !ok {
+			continue
+		}
+
+		fmt.Fprintln(os.Stderr, "[ WARNING ] --> "+arg+" is deprecated, use: clerk "+legacy.path)
+
+		translated := strings.Fields(legacy.path)
+		if legacy.interactive {
+			translated = append(translated, "-interactive")
+		}
+		translated = append(translated, args[:i]...)
+		return append(translated, args[i+1:]...)
+	}
+
+	return args
+}
+
+// finds the command named by the leading arguments and returns it with the remaining arguments
+func (cmd *command) find(args []string) (*command, []string) {
+	for len(args) > 0 {
+		next := cmd.subcommand(args[0])
+		if next == nil {
+			break
+		}
+		cmd, args = next, args[1:]
+	}
+	return cmd, args
+}
+
+func (cmd *command) subcommand(name string) *command {
+	for _, sub := range cmd.commands {
+		if sub.name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// builds the flag set of a command: the common flags plus the option flags it takes
+func (cmd *command) flagSet(path string, o *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(path, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	addCommonFlags(fs, o)
+
+	if cmd.run == nil {
+		return fs
+	}
+
+	// prompting is on by default at a terminal, and can never happen in a pipeline
+	interactive := stdinIsTerminal()
+	fs.BoolVar(&o.interactive, "interactive", interactive, "Prompt for the required values that were not supplied (default when stdin is a terminal)")
+	if cmd.mutates {
+		fs.BoolVar(&o.dryRun, "dry-run", false, "Print the planned changes to the datastores and inventory files without writing anything")
+	}
+	for _, name := range cmd.flags {
+		def := optionFlags[name]
+		usage := def.usage
+		if name == "datastore" && cmd.allDatastores {
+			usage = "Datastore to run against (provisioner|custodian|all)"
+		}
+		if cmd.requires(name) {
+			usage += " (required)"
+		}
+		fs.StringVar(def.value(o), name, "", usage)
+	}
+
+	return fs
+}
+
+// adds the flags every command and the ansible switches take
+func addCommonFlags(fs *flag.FlagSet, o *options) {
+	fs.StringVar(&o.config, "config", "", "Path to the clerk configuration file (defaults to $CAPERNICUS_CONFIG or "+inventory.DEFAULTCONFIG+")")
+	fs.StringVar(&o.environment, "environment", "", "The name of an Ansible Compute Environment (defaults to $CAPERNICUS_ENV)")
+}
+
+func (cmd *command) takes(name string) bool {
+	for _, flagName := range cmd.flags {
+		if flagName == name {
+			return true
+		}
+	}
+	return false
+}
+
+func (cmd *command) requires(name string) bool {
+	for _, required := range cmd.required {
+		if required == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parses the flags of a command, printing the help of the command for -h and on errors
+func (cmd *command) parse(path string, args []string) *options {
+	o := &options{}
+	fs := cmd.flagSet(path, o)
+
+	err := fs.Parse(args)
+
+	// the argument may come before or between the flags
+	if err == nil && cmd.arg != "" && fs.NArg() > 0 && *optionFlags[cmd.arg].value(o) == "" {
+		*optionFlags[cmd.arg].value(o) = fs.Arg(0)
+		err = fs.Parse(fs.Args()[1:])
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		cmd.printHelp(os.Stdout, path, fs)
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "\n[ ERROR ] --> "+err.Error()+".")
+		cmd.printHelp(os.Stderr, path, fs)
+		os.Exit(EXITUSAGE)
+	}
+	if fs.NArg() > 0 {
+		fmt.Fprintln(os.Stderr, "\n[ ERROR ] --> Unknown command or argument: "+fs.Arg(0)+".")
+		cmd.printHelp(os.Stderr, path, fs)
+		os.Exit(EXITUSAGE)
+	}
+
+	return o
+}
+
+// prompts for the required values that were not supplied when running interactively, and
+// rejects the run when any is still missing
+func (cmd *command) complete(o *options) {
+	missing := []string{}
+	for _, name := range cmd.required {
+		value := optionFlags[name].value(o)
+		if *value == "" && o.interactive {
+			*value = prompt(optionFlags[name].prompt)
+		}
+		if *value == "" {
+			missing = append(missing, name)
+		}
+	}
+
+	for _, name := range missing {
+		fmt.Fprint(os.Stderr, "\n[ ERROR ] --> You did not provide a value for the flag -"+name+" when it is required.\n")
+	}
+	if len(missing) > 0 {
+		fmt.Fprint(os.Stderr, "\n")
+		os.Exit(EXITINVALID)
+	}
+}
+
+// stdin is shared by every prompt so that input buffered by one prompt is not lost to the next
+var stdin = bufio.NewReader(os.Stdin)
+
+// reports whether clerk can prompt: only a person at a terminal can answer, a pipeline cannot
+func stdinIsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
+// prints a prompt on stderr, keeping stdout for the output of the command, and returns
+// the trimmed line the user entered
+func prompt(label string) string {
+	fmt.Fprint(os.Stderr, "\n"+label+": ")
+	line, _ := stdin.ReadString('\n')
+	return strings.TrimSpace(line)
+}
+
+// prints the usage of a command, its subcommands or its flags
+func (cmd *command) printHelp(w io.Writer, path string, fs *flag.FlagSet) {
+	if cmd.run == nil {
+		fmt.Fprintln(w, "\nUsage: "+path+" <command> [flags]")
+		if cmd.summary != "" {
+			fmt.Fprintln(w, "\n"+cmd.summary)
+		}
+		if path == "clerk" {
+			fmt.Fprintln(w, "\nAnsible dynamic inventory:")
+			fmt.Fprintln(w, "  clerk --list                 Print the inventory of the environment as JSON")
+			fmt.Fprintln(w, "  clerk --host <fqdn>          Print the variables of a host as JSON")
+		}
+		fmt.Fprintln(w, "\nCommands:")
+		cmd.printCommands(w, "")
+		fmt.Fprintln(w, "\nRun '"+path+" <command> -help' for the flags of a command.")
+		return
+	}
+
+	usage := "\nUsage: " + path + " [flags]"
+	if cmd.arg != "" {
+		usage += " [" + cmd.arg + "]"
+	}
+	fmt.Fprintln(w, usage)
+	fmt.Fprintln(w, "\n"+cmd.summary)
+	fmt.Fprintln(w, "\nFlags:")
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+	fs.SetOutput(io.Discard)
+}
+
+// prints the runnable commands below a command, one per line with their summary
+func (cmd *command) printCommands(w io.Writer, prefix string) {
+	names := make([]string, 0, len(cmd.commands))
+	byName := make(map[string]*command)
+	for _, sub := range cmd.commands {
+		names = append(names, sub.name)
+		byName[sub.name] = sub
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sub := byName[name]
+		if sub.run == nil {
+			sub.printCommands(w, prefix+name+" ")
+			continue
+		}
+		fmt.Fprintf(w, "  %-28s %s\n", prefix+name, sub.summary)
+	}
+}
+
+// splits a comma delimited flag value, dropping empty items
+func splitList(list string) []string {
+	items := []string{}
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// returns the datastores a command runs against, validating the -datastore value
+func (cmd *command) datastores(o *options) []string {
+	if o.datastore == "all" && cmd.allDatastores {
+		return []string{inventory.PROVISIONER, inventory.CUSTODIAN}
+	}
+	if !CONFIG.ValidDatastore(o.datastore) {
+		exit(EXITINVALID, "ERROR", "The datastore: "+o.datastore+" is not a valid datastore.")
+	}
+	return []string{o.datastore}
+}
@@ -0,0 +1,554 @@
+package main
+
+import (
+	"fmt"
+	"github.com/raiderops/capernicus/inventory"
+)
+
+// The command tree of clerk, see cli.go for how commands are parsed and run
+var commands = &command{
+	name: "clerk",
+	commands: []*command{
+		{
+			name:    "host",
+			summary: "Manage the hosts of an environment",
+			commands: []*command{
+				{
+					name:     "add",
+					summary:  "Add a host to an environment, optionally attaching it to groups",
+					flags:    []string{"datastore", "fqdn", "osType", "osVersion", "archType", "groups"},
+					required: []string{"datastore", "fqdn", "osType", "osVersion", "archType"},
+					mutates:  true,
+					run:      runHostAdd,
+				},
+				{
+					name:     "clone",
+					summary:  "Create a host from the groups and variables of a template host",
+					flags:    []string{"datastore", "template", "clone"},
+					required: []string{"datastore", "template", "clone"},
+					mutates:  true,
+					run:      runHostClone,
+				},
+				{
+					name:     "delete",
+					summary:  "Delete a host and remove it from every group",
+					flags:    []string{"datastore", "fqdn"},
+					required: []string{"datastore", "fqdn"},
+					mutates:  true,
+					run:      runHostDelete,
+				},
+				{
+					name:     "attach",
+					summary:  "Attach a host to one or more groups",
+					flags:    []string{"datastore", "fqdn", "groups"},
+					required: []string{"datastore", "fqdn", "groups"},
+					mutates:  true,
+					run:      runHostAttach,
+				},
+				{
+					name:          "detach",
+					summary:       "Detach a host from one or more groups",
+					flags:         []string{"datastore", "fqdn", "groups"},
+					required:      []string{"datastore", "fqdn", "groups"},
+					allDatastores: true,
+					mutates:       true,
+					run:           runHostDetach,
+				},
+				{
+					name:     "move",
+					summary:  "Move a host from one group to another",
+					flags:    []string{"datastore", "fqdn", "from-group", "to-group"},
+					required: []string{"datastore", "fqdn", "from-group", "to-group"},
+					mutates:  true,
+					run:      runHostMove,
+				},
+				{
+					name:     "show",
+					summary:  "Display the details, groups and variables of a host",
+					flags:    []string{"datastore", "fqdn", "output"},
+					required: []string{"datastore", "fqdn"},
+					run:      runHostShow,
+				},
+				{
+					name:     "list",
+					summary:  "List the hosts of an environment, optionally filtered by their facts",
+					flags:    []string{"datastore", "osType", "osVersion", "archType", "output"},
+					required: []string{"datastore"},
+					run:      runHostList,
+				},
+				{
+					name:     "set-vars",
+					summary:  "Set one or more variables on a host",
+					flags:    []string{"datastore", "fqdn", "vars"},
+					required: []string{"datastore", "fqdn", "vars"},
+					mutates:  true,
+					run:      runHostSetVars,
+				},
+				{
+					name:     "unset-vars",
+					summary:  "Remove one or more variables from a host",
+					flags:    []string{"datastore", "fqdn", "vars"},
+					required: []string{"datastore", "fqdn", "vars"},
+					mutates:  true,
+					run:      runHostUnsetVars,
+				},
+			},
+		},
+		{
+			name:    "group",
+			summary: "Manage the groups of an environment",
+			commands: []*command{
+				{
+					name:          "add",
+					summary:       "Add a group to an environment",
+					flags:         []string{"datastore", "group", "description"},
+					required:      []string{"datastore", "group", "description"},
+					allDatastores: true,
+					mutates:       true,
+					run:           runGroupAdd,
+				},
+				{
+					name:          "delete",
+					summary:       "Delete a group and remove it from its hosts and parent groups",
+					flags:         []string{"datastore", "group"},
+					required:      []string{"datastore", "group"},
+					allDatastores: true,
+					mutates:       true,
+					run:           runGroupDelete,
+				},
+				{
+					name:     "show",
+					summary:  "Display the details, hosts, children and variables of a group",
+					flags:    []string{"datastore", "group", "output"},
+					required: []string{"datastore", "group"},
+					run:      runGroupShow,
+				},
+				{
+					name:     "list",
+					summary:  "List the groups of an environment with their descriptions",
+					flags:    []string{"datastore", "output"},
+					required: []string{"datastore"},
+					run:      runGroupList,
+				},
+				{
+					name:     "names",
+					summary:  "List the names of the groups of an environment, one per line",
+					flags:    []string{"datastore", "output"},
+					required: []string{"datastore"},
+					run:      runGroupNames,
+				},
+				{
+					name:     "set-vars",
+					summary:  "Set one or more variables on a group",
+					flags:    []string{"datastore", "group", "vars"},
+					required: []string{"datastore", "group", "vars"},
+					mutates:  true,
+					run:      runGroupSetVars,
+				},
+				{
+					name:     "unset-vars",
+					summary:  "Remove one or more variables from a group",
+					flags:    []string{"datastore", "group", "vars"},
+					required: []string{"datastore", "group", "vars"},
+					mutates:  true,
+					run:      runGroupUnsetVars,
+				},
+				{
+					name:     "add-child",
+					summary:  "Nest a child group under a group",
+					flags:    []string{"datastore", "group", "child"},
+					required: []string{"datastore", "group", "child"},
+					mutates:  true,
+					run:      runGroupAddChild,
+				},
+				{
+					name:     "remove-child",
+					summary:  "Remove a child group from a group",
+					flags:    []string{"datastore", "group", "child"},
+					required: []string{"datastore", "group", "child"},
+					mutates:  true,
+					run:      runGroupRemoveChild,
+				},
+			},
+		},
+		{
+			name:    "env",
+			summary: "Manage the environments of a datastore",
+			commands: []*command{
+				{
+					name:          "create",
+					summary:       "Create the environment selected by -environment and its inventory file",
+					flags:         []string{"datastore"},
+					required:      []string{"datastore"},
+					allDatastores: true,
+					mutates:       true,
+					run:           runEnvCreate,
+				},
+			},
+		},
+		{
+			name:    "inventory",
+			summary: "Manage the inventory files of an environment",
+			commands: []*command{
+				{
+					name:     "backups",
+					summary:  "List the backups of the inventory file, oldest first",
+					flags:    []string{"datastore", "output"},
+					required: []string{"datastore"},
+					run:      runInventoryBackups,
+				},
+				{
+					name:     "diff",
+					summary:  "Compare the datastore with the inventory file on disk or a backup of it",
+					flags:    []string{"datastore", "timestamp", "show", "output"},
+					required: []string{"datastore"},
+					run:      runInventoryDiff,
+				},
+				{
+					name:     "rollback",
+					summary:  "Put a backup of the inventory file back in place",
+					flags:    []string{"datastore", "timestamp"},
+					required: []string{"datastore", "timestamp"},
+					arg:      "timestamp",
+					mutates:  true,
+					run:      runInventoryRollback,
+				},
+			},
+		},
+		{
+			name:     "push",
+			summary:  "Move hosts from the provisioner datastore to the custodian datastore",
+			flags:    []string{"hosts"},
+			required: []string{"hosts"},
+			mutates:  true,
+			run:      runPush,
+		},
+		{
+			name:     "pull",
+			summary:  "Move hosts from the custodian datastore back to the provisioner datastore",
+			flags:    []string{"hosts"},
+			required: []string{"hosts"},
+			mutates:  true,
+			run:      runPull,
+		},
+		{
+			name:    "resume",
+			summary: "Finish or roll back the pushes and pulls that were interrupted",
+			mutates: true,
+			run:     runResume,
+		},
+		{
+			name:    "audit",
+			summary: "Show who changed the hosts and groups of an environment, and when",
+			flags:   []string{"fqdn", "group", "user", "since", "until", "output"},
+			run:     runAudit,
+		},
+	},
+}
+
+func runHostAdd(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+
+	if c.hostExists(o.fqdn, ENV, o.datastore) {
+		exit(EXITCONFLICT, "FAILED", "The Host: "+o.fqdn+" already exists in Environment: "+ENV+".")
+	}
+
+	// validate the groups before adding the host so that a typo does not leave a half attached host
+	groupList := splitList(o.groups)
+	for _, g := range groupList {
+		c.requireGroup(g, o.datastore)
+	}
+
+	aHost := inventory.AnsibleHost{Fqdn: o.fqdn, Groups: make(map[string]bool), Environment: ENV, OsType: o.osType, OsVersion: o.osVersion, ArchType: o.archType, Vars: make(map[string]string)}
+	c.addHost(aHost, o.datastore)
+
+	for _, g := range groupList {
+		c.attachHost(o.fqdn, g, ENV, o.datastore)
+	}
+
+	c.refreshInventoryFile(o.datastore)
+}
+
+func runHostClone(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+
+	// create the new host using the supplied template host
+	c.cloneHost(o.template, o.clone, ENV, o.datastore)
+	c.refreshInventoryFile(o.datastore)
+}
+
+func runHostDelete(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.requireHost(o.fqdn, o.datastore)
+
+	fmt.Println("\nDeleting host: " + o.fqdn + "............\n")
+	c.deleteHost(o.fqdn, ENV, o.datastore)
+	fmt.Println("\n[ OK ] --> Successfully deleted host: " + o.fqdn + "\n")
+
+	c.refreshInventoryFile(o.datastore)
+}
+
+func runHostAttach(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.requireHost(o.fqdn, o.datastore)
+
+	groupList := splitList(o.groups)
+	for _, g := range groupList {
+		c.requireGroup(g, o.datastore)
+	}
+
+	for _, g := range groupList {
+		c.attachHost(o.fqdn, g, ENV, o.datastore)
+	}
+
+	c.refreshInventoryFile(o.datastore)
+}
+
+func runHostDetach(c *clerk, o *options) {
+	groupList := splitList(o.groups)
+	for _, ds := range o.datastores {
+		c.requireEnv(ds)
+		c.requireHost(o.fqdn, ds)
+		for _, g := range groupList {
+			c.requireGroup(g, ds)
+		}
+
+		for _, g := range groupList {
+			c.detachHost(o.fqdn, g, ENV, ds)
+			fmt.Println("\n[ OK ] --> Successfully detached host: " + o.fqdn + " from group: " + g + " in " + ds + "............\n")
+		}
+
+		c.refreshInventoryFile(ds)
+	}
+}
+
+func runHostMove(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.requireHost(o.fqdn, o.datastore)
+	c.requireGroup(o.toGroup, o.datastore)
+	c.requireGroup(o.fromGroup, o.datastore)
+
+	fmt.Println("\nDetaching host: " + o.fqdn + " from group: " + o.fromGroup + "............\n")
+	c.detachHost(o.fqdn, o.fromGroup, ENV, o.datastore)
+	fmt.Println("\n[ OK ] --> Successfully detached host: " + o.fqdn + " from group: " + o.fromGroup + "............\n")
+
+	c.attachHost(o.fqdn, o.toGroup, ENV, o.datastore)
+	c.refreshInventoryFile(o.datastore)
+}
+
+func runHostShow(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.requireHost(o.fqdn, o.datastore)
+	c.displayHost(o.fqdn, ENV, o.datastore, o.output)
+}
+
+func runHostList(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.listHostOptions(ENV, o.datastore, hostFilter(o.osType, o.osVersion, o.archType), o.output)
+}
+
+func runHostSetVars(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.requireHost(o.fqdn, o.datastore)
+
+	varMap, err := inventory.ParseVars(o.vars)
+	if err != nil {
+		fail(err)
+	}
+	c.setHostVars(o.fqdn, ENV, o.datastore, varMap)
+
+	// the yaml and json inventory files carry the variables of the hosts
+	c.refreshInventoryFile(o.datastore)
+}
+
+func runHostUnsetVars(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.requireHost(o.fqdn, o.datastore)
+	c.unsetHostVars(o.fqdn, ENV, o.datastore, splitList(o.vars))
+	c.refreshInventoryFile(o.datastore)
+}
+
+func runGroupAdd(c *clerk, o *options) {
+	dsList := o.datastores
+	for _, ds := range dsList {
+		c.requireEnv(ds)
+	}
+
+	added := 0
+	for _, ds := range dsList {
+		if c.groupExists(o.group, ENV, ds) {
+			fmt.Println("\n[ INFO ] --> The Group: " + o.group + " already exists in Environment: " + ENV + " in datastore: " + ds + "...skipping add.\n")
+			continue
+		}
+
+		// setup the group members map with empty members slice
+		aGroup := inventory.AnsibleGroups{Members: map[string][]string{o.group: make([]string, 0)}, Description: o.description, Environment: ENV, Name: o.group, Vars: make(map[string]string), Children: make([]string, 0)}
+		c.addGroup(aGroup, ds)
+		c.refreshInventoryFile(ds)
+		added++
+	}
+
+	if added == 0 {
+		exit(EXITCONFLICT, "FAILED", "The Group: "+o.group+" already exists in Environment: "+ENV+" in datastore: "+o.datastore+".")
+	}
+}
+
+func runGroupDelete(c *clerk, o *options) {
+	dsList := o.datastores
+	for _, ds := range dsList {
+		c.requireEnv(ds)
+	}
+
+	for _, ds := range dsList {
+		if !c.groupExists(o.group, ENV, ds) {
+			// deleting a group that exists nowhere is an error for a single datastore only, as before
+			if o.datastore != "all" {
+				exit(EXITNOTFOUND, "ERROR", "Group: "+o.group+" does not exist in datastore: "+ds+".")
+			}
+			fmt.Println("\n[ INFO ] --> Group: " + o.group + " does not exist in datastore: " + ds + "...skipping delete.\n")
+			continue
+		}
+
+		fmt.Println("\n[ INFO ] --> Deleting group: " + o.group + " from Environment: " + ENV + " in datastore: " + ds + "............\n")
+		c.deleteGroup(o.group, ENV, ds)
+		fmt.Println("\n[ OK ] --> Successfully deleted group: " + o.group + " from Environment: " + ENV + " in datastore: " + ds + ".\n")
+		c.refreshInventoryFile(ds)
+	}
+}
+
+func runGroupShow(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.requireGroup(o.group, o.datastore)
+	c.displayGroup(o.group, ENV, o.datastore, o.output)
+}
+
+func runGroupList(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.listGroups(ENV, o.datastore, o.output)
+}
+
+func runGroupNames(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.listGroupOptions(ENV, o.datastore, o.output)
+}
+
+func runGroupSetVars(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.requireGroup(o.group, o.datastore)
+
+	varMap, err := inventory.ParseVars(o.vars)
+	if err != nil {
+		fail(err)
+	}
+	c.setGroupVars(o.group, ENV, o.datastore, varMap)
+	c.refreshInventoryFile(o.datastore)
+}
+
+func runGroupUnsetVars(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.requireGroup(o.group, o.datastore)
+	c.unsetGroupVars(o.group, ENV, o.datastore, splitList(o.vars))
+	c.refreshInventoryFile(o.datastore)
+}
+
+func runGroupAddChild(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.requireGroup(o.group, o.datastore)
+	c.requireGroup(o.child, o.datastore)
+	c.addChildGroup(o.group, o.child, ENV, o.datastore)
+	c.refreshInventoryFile(o.datastore)
+}
+
+func runGroupRemoveChild(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.requireGroup(o.group, o.datastore)
+	c.removeChildGroup(o.group, o.child, ENV, o.datastore)
+	c.refreshInventoryFile(o.datastore)
+}
+
+func runEnvCreate(c *clerk, o *options) {
+	dsList := o.datastores
+	for _, ds := range dsList {
+		if c.envExists(ENV, ds) {
+			exit(EXITCONFLICT, "FAILED", "The environment: "+ENV+" already exists in the database: "+ds+".")
+		}
+	}
+
+	anEnvironment := &inventory.AnsibleEnvironment{Name: ENV, Prefix: inventory.EnvPrefix(ENV)}
+	for _, ds := range dsList {
+		c.addEnvironment(anEnvironment, ds)
+		fmt.Println("\n[ INFO ] --> Creating Inventory file for Environment: " + ENV + " in " + ds + "...............\n")
+		c.createInventoryFile(ENV, ds)
+		fmt.Println("\n[ OK ] --> Successfully Created Inventory File for " + ENV + " in " + ds + ".\n")
+	}
+}
+
+func runInventoryBackups(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.listInventoryBackups(ENV, o.datastore, o.output)
+}
+
+func runInventoryDiff(c *clerk, o *options) {
+	if o.show != "" && o.show != "diff" && o.show != "summary" {
+		exit(EXITINVALID, "ERROR", "The value: "+o.show+" of -show is not one of diff or summary.")
+	}
+	c.requireEnv(o.datastore)
+	c.diffInventoryFile(ENV, o.datastore, o.timestamp, o.show == "summary", o.output)
+}
+
+func runInventoryRollback(c *clerk, o *options) {
+	c.requireEnv(o.datastore)
+	c.rollbackInventoryFile(ENV, o.datastore, o.timestamp)
+}
+
+func runPush(c *clerk, o *options) {
+	for _, h := range splitList(o.hosts) {
+		c.requireHost(h, inventory.PROVISIONER)
+		c.pushOneHost(h)
+	}
+}
+
+func runPull(c *clerk, o *options) {
+	for _, h := range splitList(o.hosts) {
+		c.pullOneHost(h)
+	}
+}
+
+func runResume(c *clerk, o *options) {
+	c.resumeMoves()
+}
+
+func runAudit(c *clerk, o *options) {
+	filter := inventory.AuditFilter{Environment: ENV, Host: o.fqdn, Group: o.group, User: o.user}
+	filter.Since = parseTime("since", o.since)
+	filter.Until = parseTime("until", o.until)
+	c.listAuditRecords(filter, o.output)
+}
+
+// exits when the environment of this run does not exist in a datastore
+func (c *clerk) requireEnv(database string) {
+	if !c.envExists(ENV, database) {
+		exit(EXITNOTFOUND, "ERROR", "The Environment: "+ENV+" does not exist in the database: "+database+".")
+	}
+}
+
+// exits when a host does not exist in the environment of this run
+func (c *clerk) requireHost(hostName, database string) {
+	if !c.hostExists(hostName, ENV, database) {
+		exit(EXITNOTFOUND, "FAILED", "The Host: "+hostName+" does not exist in Environment: "+ENV+" in database: "+database+".")
+	}
+}
+
+// exits when a group does not exist in the environment of this run
+func (c *clerk) requireGroup(groupName, database string) {
+	if !c.groupExists(groupName, ENV, database) {
+		exit(EXITNOTFOUND, "FAILED", "The Group: "+groupName+" does not exist in Environment: "+ENV+" in datastore: "+database+".")
+	}
+}
+
+// rewrites the inventory file of the environment of this run after a change
+func (c *clerk) refreshInventoryFile(database string) {
+	fmt.Println("\n[ INFO ] --> Updating Inventory file for Environment: " + ENV + " in " + database + "...............\n")
+	c.updateInventoryFile(ENV, database)
+	fmt.Println("\n[ OK ] --> Successfully Updated Inventory File for " + ENV + " in " + database + ".\n")
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/raiderops/capernicus/inventory"
+	"os"
+	"sort"
+	"strings"
+)
+
+// runs a command against an in-memory copy of the environment and prints the changes it
+// would make to the documents of the datastores and to the inventory files
+func (c *clerk) dryRun(cmd *command, o *options) {
+	plan, err := c.inv.DryRun(ENV)
+	if err != nil {
+		fail(err)
+	}
+
+	fmt.Print("\n[ DRY RUN ] --> Nothing is written, the datastores and inventory files are left as they are.\n\n")
+	cmd.run(&clerk{inv: plan}, o)
+
+	changes, err := inventory.Changes(c.inv, plan)
+	if err != nil {
+		fail(err)
+	}
+
+	files := plan.PlannedFiles()
+	paths := make([]string, 0, len(files))
+	for path := range files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	fileDiffs := []string{}
+	for _, path := range paths {
+		// a file that does not exist yet is diffed as empty
+		current, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			fail(err)
+		}
+		if diff := inventory.UnifiedDiff(path, path+" (planned)", current, files[path]); diff != "" {
+			fileDiffs = append(fileDiffs, diff)
+		}
+	}
+
+	if len(changes) == 0 && len(fileDiffs) == 0 {
+		fmt.Print("\n[ DRY RUN ] --> No changes.\n\n")
+		return
+	}
+
+	if len(changes) > 0 {
+		fmt.Print("\n[ DRY RUN ] --> Planned changes to the datastores:\n\n")
+		for _, change := range changes {
+			printChange(change)
+		}
+	}
+
+	for _, diff := range fileDiffs {
+		fmt.Print("\n[ DRY RUN ] --> Planned changes to the inventory file:\n\n" + diff)
+	}
+	fmt.Println()
+}
+
+// prints a planned change: the whole document for an insert or removal, and the fields that
+// change for an update, with group memberships as the names added and removed
+func printChange(change inventory.Change) {
+	fmt.Println(change.Action + " " + change.Kind + " " + change.Name + " in " + change.Datastore)
+
+	switch change.Action {
+	case inventory.CHANGEINSERT:
+		fmt.Println("    " + compactJSON(change.After))
+		return
+	case inventory.CHANGEREMOVE:
+		fmt.Println("    " + compactJSON(change.Before))
+		return
+	}
+
+	before, after := documentFields(change.Before), documentFields(change.After)
+	fields := make([]string, 0, len(after))
+	for field := range after {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	for _, field := range fields {
+		oldValue, newValue := compactJSON(before[field]), compactJSON(after[field])
+		if oldValue == newValue {
+			continue
+		}
+
+		switch field {
+		case "Members":
+			group := change.After.(inventory.AnsibleGroups)
+			oldMembers := change.Before.(inventory.AnsibleGroups).Members[group.Name]
+			fmt.Println("    members: " + nameDiff(oldMembers, group.Members[group.Name]))
+		case "Groups":
+			fmt.Println("    groups: " + nameDiff(trueKeys(before[field]), trueKeys(after[field])))
+		default:
+			fmt.Println("    " + strings.ToLower(field) + ": " + oldValue + " -> " + newValue)
+		}
+	}
+}
+
+// returns the top level fields of a document
+func documentFields(doc interface{}) map[string]interface{} {
+	fields := make(map[string]interface{})
+	b, err := json.Marshal(doc)
+	if err == nil {
+		json.Unmarshal(b, &fields)
+	}
+	return fields
+}
+
+// returns the keys flagged true of a decoded map[string]bool field, such as the groups of a host
+func trueKeys(field interface{}) []string {
+	flags, _ := field.(map[string]interface{})
+	keys := []string{}
+	for key, flag := range flags {
+		if flag == true {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// describes the names added to and removed from a list as "+added -removed"
+func nameDiff(before, after []string) string {
+	had := make(map[string]bool)
+	for _, name := range before {
+		had[name] = true
+	}
+	has := make(map[string]bool)
+	for _, name := range after {
+		has[name] = true
+	}
+
+	diff := []string{}
+	for _, name := range after {
+		if !had[name] {
+			diff = append(diff, "+"+name)
+		}
+	}
+	for _, name := range before {
+		if !has[name] {
+			diff = append(diff, "-"+name)
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool { return diff[i][1:] < diff[j][1:] })
+	return strings.Join(diff, " ")
+}
+
+// encodes a document on one line
+func compactJSON(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
@@ -0,0 +1,277 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Audit collection, every change to the inventory is recorded in the provisioner datastore
+// together with who made it
+const AUDITCOLLECTION string = "audit"
+
+// Audit results
+const AUDITOK string = "ok"
+const AUDITFAILED string = "failed"
+
+// Audited operations, one for every method of Store that changes the datastores
+const (
+	AUDITADDHOST          string = "add host"
+	AUDITCLONEHOST        string = "clone host"
+	AUDITATTACHHOST       string = "attach host"
+	AUDITDETACHHOST       string = "detach host"
+	AUDITDELETEHOST       string = "delete host"
+	AUDITSETHOSTVARS      string = "set host vars"
+	AUDITUNSETHOSTVARS    string = "unset host vars"
+	AUDITADDGROUP         string = "add group"
+	AUDITDELETEGROUP      string = "delete group"
+	AUDITSETGROUPVARS     string = "set group vars"
+	AUDITUNSETGROUPVARS   string = "unset group vars"
+	AUDITADDCHILDGROUP    string = "add child group"
+	AUDITREMOVECHILDGROUP string = "remove child group"
+	AUDITADDENVIRONMENT   string = "add environment"
+	AUDITMOVEHOST         string = "move host"
+	AUDITRESUMEMOVE       string = "resume move"
+)
+
+// Type Definitions
+
+// AuditRecord records an operation that changed or tried to change the inventory: who ran it,
+// the hosts and groups it was about, their documents before and after it and its result
+type AuditRecord struct {
+	Id          string `bson:"_id" json:"id"`
+	Time        time.Time
+	User        string
+	Hostname    string
+	Command     string
+	Operation   string
+	Environment string
+	Hosts       []string
+	Groups      []string
+	Before      []AuditDocuments
+	After       []AuditDocuments
+	Result      string
+	Error       string
+}
+
+// AuditDocuments holds the documents of a datastore an operation was about. The environment
+// is only recorded when the operation changed it.
+type AuditDocuments struct {
+	Datastore   string
+	Environment *AnsibleEnvironment
+	Hosts       []AnsibleHost
+	Groups      []AnsibleGroups
+}
+
+// AuditActor is who runs the operations of a store, as it is written to the audit records
+type AuditActor struct {
+	User     string
+	Hostname string
+	Command  string
+}
+
+// AuditFilter narrows down the audit records, empty fields match any record
+type AuditFilter struct {
+	Environment string
+	Host        string
+	Group       string
+	User        string
+	Since       time.Time
+	Until       time.Time
+}
+
+// reports whether a record matches the filter
+func (filter AuditFilter) matches(record AuditRecord) bool {
+	return (filter.Environment == "" || filter.Environment == record.Environment) &&
+		(filter.Host == "" || contains(record.Hosts, filter.Host)) &&
+		(filter.Group == "" || contains(record.Groups, filter.Group)) &&
+		(filter.User == "" || filter.User == record.User) &&
+		(filter.Since.IsZero() || !record.Time.Before(filter.Since)) &&
+		(filter.Until.IsZero() || !record.Time.After(filter.Until))
+}
+
+// SetActor sets who the audit records of the store name, by default the user running the
+// process on this machine and its command line
+func (s *Store) SetActor(actor AuditActor) {
+	s.actor = actor
+}
+
+// SetAuditWarning sets a function that is handed the error when the audit record of an operation
+// cannot be written. The operation then returns its own result, so that a change that was made
+// is not reported as failed. Without one, the error is returned by the operation.
+func (s *Store) SetAuditWarning(warn func(err error)) {
+	s.auditWarning = warn
+}
+
+// AuditRecords returns the audit records that match the filter, oldest first
+func (s *Store) AuditRecords(filter AuditFilter) ([]AuditRecord, error) {
+	return s.backend.AuditRecords(filter)
+}
+
+// returns the user running the process, the user who ran sudo when there is one, together
+// with the machine and command line
+func currentActor() AuditActor {
+	actor := AuditActor{User: os.Getenv("SUDO_USER"), Command: strings.Join(os.Args, " ")}
+	if actor.User == "" {
+		if current, err := user.Current(); err == nil {
+			actor.User = current.Username
+		} else {
+			actor.User = os.Getenv("USER")
+		}
+	}
+	actor.Hostname, _ = os.Hostname()
+	return actor
+}
+
+// an operation being audited, see startAudit
+type audit struct {
+	store      *Store
+	record     AuditRecord
+	datastores []string
+}
+
+// starts the audit of an operation on an environment of one or more datastores, recording the
+// documents of the hosts and groups it is about before it runs
+func (s *Store) startAudit(operation, envName string, datastores, hosts, groups []string) *audit {
+	a := &audit{
+		store:      s,
+		datastores: datastores,
+		record: AuditRecord{
+			Time:        time.Now(),
+			User:        s.actor.User,
+			Hostname:    s.actor.Hostname,
+			Command:     s.actor.Command,
+			Operation:   operation,
+			Environment: envName,
+			Hosts:       hosts,
+			Groups:      groups,
+		},
+	}
+	a.record.Before = a.snapshot()
+	return a
+}
+
+// finishes the audit of an operation with its error, and writes the record. The error of the
+// operation is returned, an error writing the record goes to the audit warning of the store or
+// is returned with it when there is none.
+func (a *audit) finish(err error) error {
+	a.record.After = a.snapshot()
+	a.record.Result = AUDITOK
+	if err != nil {
+		a.record.Result = AUDITFAILED
+		a.record.Error = err.Error()
+	}
+
+	// an environment is only recorded when the operation changed it
+	for i := range a.record.Before {
+		before, after := a.record.Before[i].Environment, a.record.After[i].Environment
+		if before != nil && after != nil && sameDocument(before, after) {
+			a.record.Before[i].Environment, a.record.After[i].Environment = nil, nil
+		}
+	}
+
+	// the groups of the hosts the operation was about are part of the record
+	for _, docs := range append(a.record.Before, a.record.After...) {
+		for _, host := range docs.Hosts {
+			for group := range host.Groups {
+				a.record.Groups = addToSet(a.record.Groups, group)
+			}
+		}
+	}
+	sort.Strings(a.record.Groups)
+
+	auditErr := a.store.writeAudit(a.record)
+	switch {
+	case auditErr == nil:
+		return err
+	case a.store.auditWarning != nil:
+		a.store.auditWarning(fmt.Errorf("the audit record of the %s was not written: %w", a.record.Operation, auditErr))
+		return err
+	case err == nil:
+		return fmt.Errorf("the %s succeeded but its audit record was not written: %w", a.record.Operation, auditErr)
+	default:
+		return fmt.Errorf("%w, and its audit record was not written: %v", err, auditErr)
+	}
+}
+
+// reads the documents the operation is about from every datastore it works on, documents
+// that do not exist or cannot be read are left out
+func (a *audit) snapshot() []AuditDocuments {
+	s, envName := a.store, a.record.Environment
+	snapshots := make([]AuditDocuments, 0, len(a.datastores))
+
+	for _, ds := range a.datastores {
+		docs := AuditDocuments{Datastore: ds, Hosts: []AnsibleHost{}, Groups: []AnsibleGroups{}}
+		if env, err := s.backend.Environment(ds, envName); err == nil {
+			docs.Environment = &env
+		}
+		for _, hostName := range a.record.Hosts {
+			if host, err := s.backend.Host(ds, envName, hostName); err == nil {
+				docs.Hosts = append(docs.Hosts, host)
+			}
+		}
+		for _, groupName := range a.record.Groups {
+			if group, err := s.backend.Group(ds, envName, groupName); err == nil {
+				docs.Groups = append(docs.Groups, group)
+			}
+		}
+		snapshots = append(snapshots, docs)
+	}
+
+	return snapshots
+}
+
+// serializes the appends of every store of the process to the audit file
+var auditFileMu sync.Mutex
+
+// writes an audit record to the audit collection, and to the audit file when one is configured.
+// A dry run store keeps its records to itself.
+func (s *Store) writeAudit(record AuditRecord) error {
+	id, err := newId()
+	if err != nil {
+		return err
+	}
+	record.Id = id
+
+	if err = s.backend.InsertAudit(record); err != nil {
+		return err
+	}
+
+	if s.config.Audit.File == "" || s.plan != nil {
+		return nil
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	auditFileMu.Lock()
+	defer auditFileMu.Unlock()
+
+	f, err := os.OpenFile(s.config.Audit.File, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	// the record is appended with a single write so that a line is never interleaved
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// reports whether a list holds a value
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
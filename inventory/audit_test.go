@@ -0,0 +1,101 @@
+package inventory
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAudit(t *testing.T) {
+	s := newTestStore(t)
+	s.config.Audit.File = filepath.Join(t.TempDir(), "audit.jsonl")
+	s.SetActor(AuditActor{User: "alice", Hostname: "admin01", Command: "clerk host add"})
+
+	start := time.Now()
+	mustAddHost(t, s, "web01.example.com", PROVISIONER)
+	mustAddGroup(t, s, "web", PROVISIONER)
+	mustAttach(t, s, "web01.example.com", "web", PROVISIONER)
+	checkErr(t, s.AttachHost("missing.example.com", "web", testEnv, PROVISIONER), ErrNotFound)
+
+	s.SetActor(AuditActor{User: "bob"})
+	checkErr(t, s.DeleteHost("web01.example.com", testEnv, PROVISIONER), nil)
+
+	tests := []struct {
+		name   string
+		filter AuditFilter
+		want   []string
+	}{
+		{name: "host", filter: AuditFilter{Host: "web01.example.com"}, want: []string{AUDITADDHOST, AUDITATTACHHOST, AUDITDELETEHOST}},
+		{name: "group", filter: AuditFilter{Group: "web"}, want: []string{AUDITADDGROUP, AUDITATTACHHOST, AUDITATTACHHOST, AUDITDELETEHOST}},
+		{name: "user", filter: AuditFilter{User: "bob"}, want: []string{AUDITDELETEHOST}},
+		{name: "until", filter: AuditFilter{Until: start}, want: []string{AUDITADDENVIRONMENT, AUDITADDENVIRONMENT}},
+		{name: "since", filter: AuditFilter{Since: time.Now().Add(time.Hour)}, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := s.AuditRecords(tt.filter)
+			checkErr(t, err, nil)
+			got := []string{}
+			for _, record := range records {
+				got = append(got, record.Operation)
+			}
+			checkStrings(t, "operations", got, tt.want)
+		})
+	}
+
+	// a failed operation is recorded with its error
+	records, err := s.AuditRecords(AuditFilter{Host: "missing.example.com"})
+	checkErr(t, err, nil)
+	if len(records) != 1 || records[0].Result != AUDITFAILED || records[0].Error == "" {
+		t.Fatalf("failed attach recorded as %+v", records)
+	}
+
+	// a deletion records the host before and nothing after
+	records, err = s.AuditRecords(AuditFilter{User: "bob"})
+	checkErr(t, err, nil)
+	deleted := records[0]
+	if len(deleted.Before[0].Hosts) != 1 || len(deleted.After[0].Hosts) != 0 {
+		t.Errorf("delete recorded hosts %v before and %v after", deleted.Before[0].Hosts, deleted.After[0].Hosts)
+	}
+	if deleted.Result != AUDITOK {
+		t.Errorf("delete recorded result %q", deleted.Result)
+	}
+
+	// the audit file holds the records written after it was configured
+	f, err := os.Open(s.config.Audit.File)
+	checkErr(t, err, nil)
+	defer f.Close()
+	users := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		record := AuditRecord{}
+		checkErr(t, json.Unmarshal(scanner.Bytes(), &record), nil)
+		users = append(users, record.User)
+	}
+	checkStrings(t, "audit file users", users, []string{"alice", "alice", "alice", "alice", "bob"})
+}
+
+func TestAuditWriteFailure(t *testing.T) {
+	s := newTestStore(t)
+	s.config.Audit.File = filepath.Join(t.TempDir(), "missing", "audit.jsonl")
+
+	// without an audit warning the operation reports the record it could not write
+	err := s.AddHost(AnsibleHost{Fqdn: "web01.example.com", Environment: testEnv}, PROVISIONER)
+	if err == nil || !os.IsNotExist(errors.Unwrap(err)) {
+		t.Errorf("adding a host without its audit record returned %v", err)
+	}
+
+	// with one it succeeds, and the change was made either way
+	warnings := []error{}
+	s.SetAuditWarning(func(err error) { warnings = append(warnings, err) })
+	mustAddHost(t, s, "web02.example.com", PROVISIONER)
+	if len(warnings) != 1 {
+		t.Errorf("got audit warnings %v, want one", warnings)
+	}
+	checkStrings(t, "default group members", members(t, s, AllGroup(testEnv), PROVISIONER), []string{"web01.example.com", "web02.example.com"})
+}
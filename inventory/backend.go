@@ -0,0 +1,64 @@
+package inventory
+
+// Backend stores the hosts, groups, environments, inventory files and move journal of the
+// datastores. Every datastore is kept apart, hosts and groups are kept per environment.
+//
+// Lookups and updates of a single record report ErrNotFound when the record does not exist,
+// inserts report ErrAlreadyExists when it does. Membership updates must be atomic: adding a
+// member that is already present or removing one that is absent is not an error.
+type Backend interface {
+	// Hosts
+	Host(datastore, envName, hostName string) (AnsibleHost, error)
+	Hosts(datastore, envName string, filter HostFilter) ([]AnsibleHost, error)
+	InsertHost(datastore string, host AnsibleHost) error
+	PutHost(datastore string, host AnsibleHost) error
+	DeleteHost(datastore, envName, hostName string) error
+	SetHostGroup(datastore, envName, hostName, groupName string) error
+	UnsetHostGroup(datastore, envName, hostName, groupName string) error
+	UnsetGroupFromHosts(datastore, envName, groupName string) error
+	SetHostVars(datastore, envName, hostName string, varMap map[string]string) error
+	UnsetHostVars(datastore, envName, hostName string, keys []string) error
+
+	// Groups
+	Group(datastore, envName, groupName string) (AnsibleGroups, error)
+	Groups(datastore, envName string) ([]AnsibleGroups, error)
+	InsertGroup(datastore string, group AnsibleGroups) error
+	DeleteGroup(datastore, envName, groupName string) error
+	AddGroupMember(datastore, envName, groupName, hostName string) error
+	RemoveGroupMember(datastore, envName, groupName, hostName string) error
+	AddGroupChild(datastore, envName, groupName, childName string) error
+	RemoveGroupChild(datastore, envName, groupName, childName string) error
+	RemoveChildFromGroups(datastore, envName, childName string) error
+	SetGroupVars(datastore, envName, groupName string, varMap map[string]string) error
+	UnsetGroupVars(datastore, envName, groupName string, keys []string) error
+
+	// Environments
+	Environment(datastore, envName string) (AnsibleEnvironment, error)
+	InsertEnvironment(datastore string, env AnsibleEnvironment) error
+	SetEnvironmentGroup(datastore, envName, groupName string) error
+	UnsetEnvironmentGroup(datastore, envName, groupName string) error
+
+	// Inventory files
+	InventoryFile(datastore, envName string) (InventoryFile, error)
+	InsertInventoryFile(datastore string, file InventoryFile) error
+	PutInventoryFile(datastore string, file InventoryFile) error
+	DeleteInventoryFile(datastore, envName string) error
+
+	// Move journal, kept in the provisioner datastore
+	InsertMove(op MoveOperation) error
+	UpdateMove(op MoveOperation) error
+	UnfinishedMoves() ([]MoveOperation, error)
+
+	// Audit records, kept in the provisioner datastore
+	InsertAudit(record AuditRecord) error
+	AuditRecords(filter AuditFilter) ([]AuditRecord, error)
+
+	Close() error
+}
+
+// reports whether a host matches the facts of a filter, empty facts match any host
+func (filter HostFilter) matches(host AnsibleHost) bool {
+	return (filter.OsType == "" || filter.OsType == host.OsType) &&
+		(filter.OsVersion == "" || filter.OsVersion == host.OsVersion) &&
+		(filter.ArchType == "" || filter.ArchType == host.ArchType)
+}
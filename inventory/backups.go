@@ -0,0 +1,151 @@
+package inventory
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timestamp of the backups of an inventory file, sortable and free of characters shells and
+// file systems care about
+const BACKUPTIMEFORMAT string = "20060102T150405.000000Z"
+
+// InventoryBackup is an earlier version of the inventory file of an environment, kept when the
+// file was replaced
+type InventoryBackup struct {
+	Timestamp string
+	Path      string
+	Time      time.Time
+}
+
+// returns the backups directory next to an inventory file, which must exist
+func backupDir(file InventoryFile, datastore string) (string, error) {
+	dir := filepath.Join(filepath.Dir(file.Path), "backups")
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) || (err == nil && !info.IsDir()) {
+		return "", describe("backup directory", dir, datastore, ErrNotFound)
+	}
+	return dir, err
+}
+
+// keeps the current content of an inventory file as a timestamped backup, and prunes the
+// backups beyond the configured number
+func (s *Store) backupInventoryFile(file InventoryFile, datastore string, content []byte) error {
+	dir, err := backupDir(file, datastore)
+	if err != nil {
+		return err
+	}
+
+	timestamp := time.Now().UTC().Format(BACKUPTIMEFORMAT)
+	backupPath := filepath.Join(dir, filepath.Base(file.Path)+"."+timestamp)
+	if err = writeFileAtomic(backupPath, content); err != nil {
+		return err
+	}
+
+	backups, err := inventoryBackups(file, datastore)
+	if err != nil {
+		return err
+	}
+	for len(backups) > s.config.Backups.Keep {
+		if err = os.Remove(backups[0].Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		backups = backups[1:]
+	}
+
+	return nil
+}
+
+// InventoryBackups returns the backups of the inventory file of an environment, oldest first.
+// The backups of a file in an earlier format of the environment are not listed.
+func (s *Store) InventoryBackups(envName, datastore string) ([]InventoryBackup, error) {
+	file, err := s.backend.InventoryFile(datastore, envName)
+	if err != nil {
+		return nil, describe("inventory file of environment", envName, datastore, err)
+	}
+	return inventoryBackups(file, datastore)
+}
+
+// returns the backups of an inventory file, oldest first
+func inventoryBackups(file InventoryFile, datastore string) ([]InventoryBackup, error) {
+	dir, err := backupDir(file, datastore)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := filepath.Base(file.Path) + "."
+	backups := []InventoryBackup{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		backup := InventoryBackup{Timestamp: strings.TrimPrefix(name, prefix), Path: filepath.Join(dir, name)}
+		backup.Time, err = backupTime(backup.Timestamp, entry)
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, backup)
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].Time.Equal(backups[j].Time) {
+			return backups[i].Time.Before(backups[j].Time)
+		}
+		return backups[i].Timestamp < backups[j].Timestamp
+	})
+	return backups, nil
+}
+
+// returns when a backup was taken from its timestamp. Earlier releases stamped backups with
+// unix seconds, and backups stamped any other way are dated by their modification time.
+func backupTime(timestamp string, entry os.DirEntry) (time.Time, error) {
+	if t, err := time.Parse(BACKUPTIMEFORMAT, timestamp); err == nil {
+		return t, nil
+	}
+	if seconds, err := strconv.ParseInt(timestamp, 10, 64); err == nil {
+		return time.Unix(seconds, 0).UTC(), nil
+	}
+
+	info, err := entry.Info()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime().UTC(), nil
+}
+
+// RollbackInventoryFile puts a backup of the inventory file of an environment back in place. The
+// file it replaces is backed up in turn, so a rollback can itself be rolled back. The datastore
+// is left as it is, the next update of the file writes it from the datastore again.
+func (s *Store) RollbackInventoryFile(envName, datastore, timestamp string) error {
+	file, err := s.backend.InventoryFile(datastore, envName)
+	if err != nil {
+		return describe("inventory file of environment", envName, datastore, err)
+	}
+
+	backups, err := s.InventoryBackups(envName, datastore)
+	if err != nil {
+		return err
+	}
+	for _, backup := range backups {
+		if backup.Timestamp != timestamp {
+			continue
+		}
+
+		content, err := os.ReadFile(backup.Path)
+		if err != nil {
+			return err
+		}
+		return s.replaceInventoryFile(file, datastore, content)
+	}
+
+	return describe("inventory backup", timestamp, datastore, ErrNotFound)
+}
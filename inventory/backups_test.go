@@ -0,0 +1,150 @@
+package inventory
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInventoryBackups(t *testing.T) {
+	forEachBackend(t, testInventoryBackups)
+}
+
+func testInventoryBackups(t *testing.T, open openBackend) {
+	s := newTestStoreOn(t, open(t))
+	s.config.Backups.Keep = 2
+	checkErr(t, s.CreateInventoryFile(testEnv, PROVISIONER), nil)
+	file, err := s.backend.InventoryFile(PROVISIONER, testEnv)
+	checkErr(t, err, nil)
+
+	contents := []string{}
+	for _, host := range []string{"web01.example.com", "web02.example.com", "web03.example.com"} {
+		mustAddHost(t, s, host, PROVISIONER)
+		checkErr(t, s.UpdateInventoryFile(testEnv, PROVISIONER), nil)
+		content, err := os.ReadFile(file.Path)
+		checkErr(t, err, nil)
+		contents = append(contents, string(content))
+	}
+
+	// an unchanged file is neither backed up nor written
+	checkErr(t, s.UpdateInventoryFile(testEnv, PROVISIONER), nil)
+
+	backups, err := s.InventoryBackups(testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+	if len(backups) != 2 {
+		t.Fatalf("got %d backups, want the 2 kept", len(backups))
+	}
+	content, err := os.ReadFile(backups[1].Path)
+	checkErr(t, err, nil)
+	if string(content) != contents[1] {
+		t.Errorf("newest backup holds\n%s\nwant\n%s", content, contents[1])
+	}
+
+	// no temporary file is left behind
+	entries, err := os.ReadDir(filepath.Dir(file.Path))
+	checkErr(t, err, nil)
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("temporary file %s left behind", entry.Name())
+		}
+	}
+
+	checkErr(t, s.RollbackInventoryFile(testEnv, PROVISIONER, backups[0].Timestamp), nil)
+	content, err = os.ReadFile(file.Path)
+	checkErr(t, err, nil)
+	if string(content) != contents[0] {
+		t.Errorf("rolled back file holds\n%s\nwant\n%s", content, contents[0])
+	}
+
+	// the rollback backed up the file it replaced
+	backups, err = s.InventoryBackups(testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+	content, err = os.ReadFile(backups[len(backups)-1].Path)
+	checkErr(t, err, nil)
+	if string(content) != contents[2] {
+		t.Errorf("rollback backed up\n%s\nwant\n%s", content, contents[2])
+	}
+
+	checkErr(t, s.RollbackInventoryFile(testEnv, PROVISIONER, "20000101T000000.000000Z"), ErrNotFound)
+}
+
+func TestInventoryBackupDirectory(t *testing.T) {
+	forEachBackend(t, testInventoryBackupDirectory)
+}
+
+func testInventoryBackupDirectory(t *testing.T, open openBackend) {
+	s := newTestStoreOn(t, open(t))
+	checkErr(t, s.CreateInventoryFile(testEnv, CUSTODIAN), nil)
+	file, err := s.backend.InventoryFile(CUSTODIAN, testEnv)
+	checkErr(t, err, nil)
+
+	// the directories can be entered by their owner, the temporary files are created in them
+	for _, dir := range []string{filepath.Dir(file.Path), filepath.Join(filepath.Dir(file.Path), "backups")} {
+		info, err := os.Stat(dir)
+		checkErr(t, err, nil)
+		if info.Mode().Perm()&0700 != 0700 {
+			t.Errorf("directory %s has mode %v", dir, info.Mode().Perm())
+		}
+	}
+
+	// the backups of a datastore are kept next to its inventory file
+	mustAddHost(t, s, "web01.example.com", CUSTODIAN)
+	checkErr(t, s.UpdateInventoryFile(testEnv, CUSTODIAN), nil)
+	backups, err := s.InventoryBackups(testEnv, CUSTODIAN)
+	checkErr(t, err, nil)
+	if len(backups) != 1 || filepath.Dir(backups[0].Path) != filepath.Join(filepath.Dir(file.Path), "backups") {
+		t.Fatalf("got backups %v, want one next to %s", backups, file.Path)
+	}
+
+	// a missing backup directory fails the update and leaves the file alone
+	before, err := os.ReadFile(file.Path)
+	checkErr(t, err, nil)
+	checkErr(t, os.RemoveAll(filepath.Join(filepath.Dir(file.Path), "backups")), nil)
+	mustAddHost(t, s, "web02.example.com", CUSTODIAN)
+	checkErr(t, s.UpdateInventoryFile(testEnv, CUSTODIAN), ErrNotFound)
+	after, err := os.ReadFile(file.Path)
+	checkErr(t, err, nil)
+	if string(after) != string(before) {
+		t.Errorf("failed update changed the inventory file")
+	}
+}
+
+func TestCreateInventoryFileFailure(t *testing.T) {
+	forEachBackend(t, testCreateInventoryFileFailure)
+}
+
+func testCreateInventoryFileFailure(t *testing.T, open openBackend) {
+	s := newTestStoreOn(t, open(t))
+	root := filepath.Join(t.TempDir(), "missing") + "/"
+	s.config.Datastores[PROVISIONER] = DatastoreConfig{Database: PROVISIONER, InventoryRoot: root}
+
+	// a failed creation leaves no record behind
+	err := s.CreateInventoryFile(testEnv, PROVISIONER)
+	if err == nil || errors.Is(err, ErrAlreadyExists) {
+		t.Fatalf("creating an inventory file under a missing root returned %v", err)
+	}
+	_, err = s.backend.InventoryFile(PROVISIONER, testEnv)
+	checkErr(t, err, ErrNotFound)
+
+	// so it can be retried, with the directory a failed attempt left behind
+	envDir := filepath.Join(root, EnvPrefix(testEnv))
+	checkErr(t, os.MkdirAll(envDir, 0755), nil)
+	checkErr(t, s.CreateInventoryFile(testEnv, PROVISIONER), nil)
+	file, err := s.backend.InventoryFile(PROVISIONER, testEnv)
+	checkErr(t, err, nil)
+	if _, err = os.Stat(file.Path); err != nil {
+		t.Errorf("inventory file not written: %v", err)
+	}
+
+	// an existing file is never replaced by a new, empty one
+	checkErr(t, s.backend.DeleteInventoryFile(PROVISIONER, testEnv), nil)
+	checkErr(t, os.WriteFile(file.Path, []byte("[web]\n"), 0644), nil)
+	checkErr(t, s.CreateInventoryFile(testEnv, PROVISIONER), ErrAlreadyExists)
+	content, err := os.ReadFile(file.Path)
+	checkErr(t, err, nil)
+	if string(content) != "[web]\n" {
+		t.Errorf("existing inventory file replaced with\n%s", content)
+	}
+}
@@ -0,0 +1,501 @@
+package inventory
+
+import (
+	"encoding/json"
+	"go.etcd.io/bbolt"
+	"sort"
+	"time"
+)
+
+// how long to wait for another clerk run to release the datastore file
+const BOLTLOCKTIMEOUT time.Duration = 10 * time.Second
+
+// boltBackend keeps every datastore in its own bucket of a single local file, with one bucket
+// per kind of record mirroring the mongo collections. Records are stored as JSON under their
+// name, and every update runs in a single read-modify-write transaction.
+type boltBackend struct {
+	db *bbolt.DB
+}
+
+// opens the bolt backend, creating the datastore file when it does not exist yet
+func openBolt(cfg *Config) (*boltBackend, error) {
+	db, err := bbolt.Open(cfg.Bolt.Path, 0600, &bbolt.Options{Timeout: BOLTLOCKTIMEOUT})
+	if err == bbolt.ErrTimeout {
+		// another clerk run holds the file
+		return nil, unavailable(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &boltBackend{db: db}, nil
+}
+
+func (b *boltBackend) Close() error {
+	return b.db.Close()
+}
+
+// runs fn on a bucket of a datastore in a read-only transaction, the bucket is nil when
+// nothing was ever written to it
+func (b *boltBackend) view(datastore, name string, fn func(bk *bbolt.Bucket) error) error {
+	return b.db.View(func(tx *bbolt.Tx) error {
+		var bk *bbolt.Bucket
+		if ds := tx.Bucket([]byte(datastore)); ds != nil {
+			bk = ds.Bucket([]byte(name))
+		}
+		return fn(bk)
+	})
+}
+
+// runs fn on a bucket of a datastore in a read-write transaction, creating the bucket if needed
+func (b *boltBackend) update(datastore, name string, fn func(bk *bbolt.Bucket) error) error {
+	return b.db.Update(func(tx *bbolt.Tx) error {
+		ds, err := tx.CreateBucketIfNotExists([]byte(datastore))
+		if err != nil {
+			return err
+		}
+		bk, err := ds.CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return err
+		}
+		return fn(bk)
+	})
+}
+
+func boltGet(bk *bbolt.Bucket, key string, v interface{}) error {
+	if bk == nil {
+		return ErrNotFound
+	}
+	data := bk.Get([]byte(key))
+	if data == nil {
+		return ErrNotFound
+	}
+	return json.Unmarshal(data, v)
+}
+
+func boltPut(bk *bbolt.Bucket, key string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return bk.Put([]byte(key), data)
+}
+
+func boltInsert(bk *bbolt.Bucket, key string, v interface{}) error {
+	if bk.Get([]byte(key)) != nil {
+		return ErrAlreadyExists
+	}
+	return boltPut(bk, key, v)
+}
+
+func boltDelete(bk *bbolt.Bucket, key string) error {
+	if bk.Get([]byte(key)) == nil {
+		return ErrNotFound
+	}
+	return bk.Delete([]byte(key))
+}
+
+func hostsBucket(envName string) string {
+	return EnvPrefix(envName) + "_hosts"
+}
+
+func groupsBucket(envName string) string {
+	return EnvPrefix(envName) + "_groups"
+}
+
+// applies fn to a host in a single transaction
+func (b *boltBackend) modifyHost(datastore, envName, hostName string, fn func(host *AnsibleHost)) error {
+	return b.update(datastore, hostsBucket(envName), func(bk *bbolt.Bucket) error {
+		host := AnsibleHost{}
+		if err := boltGet(bk, hostName, &host); err != nil {
+			return err
+		}
+		if host.Groups == nil {
+			host.Groups = make(map[string]bool)
+		}
+		if host.Vars == nil {
+			host.Vars = make(map[string]string)
+		}
+		fn(&host)
+		return boltPut(bk, hostName, host)
+	})
+}
+
+// applies fn to a group in a single transaction
+func (b *boltBackend) modifyGroup(datastore, envName, groupName string, fn func(group *AnsibleGroups)) error {
+	return b.update(datastore, groupsBucket(envName), func(bk *bbolt.Bucket) error {
+		group := AnsibleGroups{}
+		if err := boltGet(bk, groupName, &group); err != nil {
+			return err
+		}
+		if group.Members == nil {
+			group.Members = make(map[string][]string)
+		}
+		if group.Vars == nil {
+			group.Vars = make(map[string]string)
+		}
+		fn(&group)
+		return boltPut(bk, groupName, group)
+	})
+}
+
+// applies fn to an environment in a single transaction
+func (b *boltBackend) modifyEnvironment(datastore, envName string, fn func(env *AnsibleEnvironment)) error {
+	return b.update(datastore, "environments", func(bk *bbolt.Bucket) error {
+		env := AnsibleEnvironment{}
+		if err := boltGet(bk, envName, &env); err != nil {
+			return err
+		}
+		if env.Groups == nil {
+			env.Groups = make(map[string]bool)
+		}
+		fn(&env)
+		return boltPut(bk, envName, env)
+	})
+}
+
+func (b *boltBackend) Host(datastore, envName, hostName string) (AnsibleHost, error) {
+	result := AnsibleHost{}
+	err := b.view(datastore, hostsBucket(envName), func(bk *bbolt.Bucket) error {
+		return boltGet(bk, hostName, &result)
+	})
+	return result, err
+}
+
+func (b *boltBackend) Hosts(datastore, envName string, filter HostFilter) ([]AnsibleHost, error) {
+	result := []AnsibleHost{}
+	err := b.view(datastore, hostsBucket(envName), func(bk *bbolt.Bucket) error {
+		if bk == nil {
+			return nil
+		}
+		return bk.ForEach(func(k, v []byte) error {
+			host := AnsibleHost{}
+			if err := json.Unmarshal(v, &host); err != nil {
+				return err
+			}
+			if filter.matches(host) {
+				result = append(result, host)
+			}
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (b *boltBackend) InsertHost(datastore string, host AnsibleHost) error {
+	return b.update(datastore, hostsBucket(host.Environment), func(bk *bbolt.Bucket) error {
+		return boltInsert(bk, host.Fqdn, host)
+	})
+}
+
+func (b *boltBackend) PutHost(datastore string, host AnsibleHost) error {
+	return b.update(datastore, hostsBucket(host.Environment), func(bk *bbolt.Bucket) error {
+		return boltPut(bk, host.Fqdn, host)
+	})
+}
+
+func (b *boltBackend) DeleteHost(datastore, envName, hostName string) error {
+	return b.update(datastore, hostsBucket(envName), func(bk *bbolt.Bucket) error {
+		return boltDelete(bk, hostName)
+	})
+}
+
+func (b *boltBackend) SetHostGroup(datastore, envName, hostName, groupName string) error {
+	return b.modifyHost(datastore, envName, hostName, func(host *AnsibleHost) {
+		host.Groups[groupName] = true
+	})
+}
+
+func (b *boltBackend) UnsetHostGroup(datastore, envName, hostName, groupName string) error {
+	return b.modifyHost(datastore, envName, hostName, func(host *AnsibleHost) {
+		delete(host.Groups, groupName)
+	})
+}
+
+func (b *boltBackend) UnsetGroupFromHosts(datastore, envName, groupName string) error {
+	return b.update(datastore, hostsBucket(envName), func(bk *bbolt.Bucket) error {
+		// collect the hosts first, a bucket must not be modified while iterating over it
+		updated := make(map[string]AnsibleHost)
+		err := bk.ForEach(func(k, v []byte) error {
+			host := AnsibleHost{}
+			if err := json.Unmarshal(v, &host); err != nil {
+				return err
+			}
+			if _, ok := host.Groups[groupName]; ok {
+				delete(host.Groups, groupName)
+				updated[string(k)] = host
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for key, host := range updated {
+			if err = boltPut(bk, key, host); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *boltBackend) SetHostVars(datastore, envName, hostName string, varMap map[string]string) error {
+	return b.modifyHost(datastore, envName, hostName, func(host *AnsibleHost) {
+		for k, v := range varMap {
+			host.Vars[k] = v
+		}
+	})
+}
+
+func (b *boltBackend) UnsetHostVars(datastore, envName, hostName string, keys []string) error {
+	return b.modifyHost(datastore, envName, hostName, func(host *AnsibleHost) {
+		for _, k := range keys {
+			delete(host.Vars, k)
+		}
+	})
+}
+
+func (b *boltBackend) Group(datastore, envName, groupName string) (AnsibleGroups, error) {
+	result := AnsibleGroups{}
+	err := b.view(datastore, groupsBucket(envName), func(bk *bbolt.Bucket) error {
+		return boltGet(bk, groupName, &result)
+	})
+	return result, err
+}
+
+func (b *boltBackend) Groups(datastore, envName string) ([]AnsibleGroups, error) {
+	result := []AnsibleGroups{}
+	err := b.view(datastore, groupsBucket(envName), func(bk *bbolt.Bucket) error {
+		if bk == nil {
+			return nil
+		}
+		return bk.ForEach(func(k, v []byte) error {
+			group := AnsibleGroups{}
+			if err := json.Unmarshal(v, &group); err != nil {
+				return err
+			}
+			result = append(result, group)
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (b *boltBackend) InsertGroup(datastore string, group AnsibleGroups) error {
+	return b.update(datastore, groupsBucket(group.Environment), func(bk *bbolt.Bucket) error {
+		return boltInsert(bk, group.Name, group)
+	})
+}
+
+func (b *boltBackend) DeleteGroup(datastore, envName, groupName string) error {
+	return b.update(datastore, groupsBucket(envName), func(bk *bbolt.Bucket) error {
+		return boltDelete(bk, groupName)
+	})
+}
+
+func (b *boltBackend) AddGroupMember(datastore, envName, groupName, hostName string) error {
+	return b.modifyGroup(datastore, envName, groupName, func(group *AnsibleGroups) {
+		group.Members[groupName] = addToSet(group.Members[groupName], hostName)
+	})
+}
+
+func (b *boltBackend) RemoveGroupMember(datastore, envName, groupName, hostName string) error {
+	return b.modifyGroup(datastore, envName, groupName, func(group *AnsibleGroups) {
+		group.Members[groupName] = pull(group.Members[groupName], hostName)
+	})
+}
+
+func (b *boltBackend) AddGroupChild(datastore, envName, groupName, childName string) error {
+	return b.modifyGroup(datastore, envName, groupName, func(group *AnsibleGroups) {
+		group.Children = addToSet(group.Children, childName)
+	})
+}
+
+func (b *boltBackend) RemoveGroupChild(datastore, envName, groupName, childName string) error {
+	return b.modifyGroup(datastore, envName, groupName, func(group *AnsibleGroups) {
+		group.Children = pull(group.Children, childName)
+	})
+}
+
+func (b *boltBackend) RemoveChildFromGroups(datastore, envName, childName string) error {
+	return b.update(datastore, groupsBucket(envName), func(bk *bbolt.Bucket) error {
+		// collect the groups first, a bucket must not be modified while iterating over it
+		updated := make(map[string]AnsibleGroups)
+		err := bk.ForEach(func(k, v []byte) error {
+			group := AnsibleGroups{}
+			if err := json.Unmarshal(v, &group); err != nil {
+				return err
+			}
+			if children := pull(group.Children, childName); len(children) != len(group.Children) {
+				group.Children = children
+				updated[string(k)] = group
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for key, group := range updated {
+			if err = boltPut(bk, key, group); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *boltBackend) SetGroupVars(datastore, envName, groupName string, varMap map[string]string) error {
+	return b.modifyGroup(datastore, envName, groupName, func(group *AnsibleGroups) {
+		for k, v := range varMap {
+			group.Vars[k] = v
+		}
+	})
+}
+
+func (b *boltBackend) UnsetGroupVars(datastore, envName, groupName string, keys []string) error {
+	return b.modifyGroup(datastore, envName, groupName, func(group *AnsibleGroups) {
+		for _, k := range keys {
+			delete(group.Vars, k)
+		}
+	})
+}
+
+func (b *boltBackend) Environment(datastore, envName string) (AnsibleEnvironment, error) {
+	result := AnsibleEnvironment{}
+	err := b.view(datastore, "environments", func(bk *bbolt.Bucket) error {
+		return boltGet(bk, envName, &result)
+	})
+	return result, err
+}
+
+func (b *boltBackend) InsertEnvironment(datastore string, env AnsibleEnvironment) error {
+	return b.update(datastore, "environments", func(bk *bbolt.Bucket) error {
+		return boltInsert(bk, env.Name, env)
+	})
+}
+
+func (b *boltBackend) SetEnvironmentGroup(datastore, envName, groupName string) error {
+	return b.modifyEnvironment(datastore, envName, func(env *AnsibleEnvironment) {
+		env.Groups[groupName] = true
+	})
+}
+
+func (b *boltBackend) UnsetEnvironmentGroup(datastore, envName, groupName string) error {
+	return b.modifyEnvironment(datastore, envName, func(env *AnsibleEnvironment) {
+		delete(env.Groups, groupName)
+	})
+}
+
+func (b *boltBackend) InventoryFile(datastore, envName string) (InventoryFile, error) {
+	result := InventoryFile{}
+	err := b.view(datastore, "inventory_files", func(bk *bbolt.Bucket) error {
+		return boltGet(bk, envName, &result)
+	})
+	return result, err
+}
+
+func (b *boltBackend) InsertInventoryFile(datastore string, file InventoryFile) error {
+	return b.update(datastore, "inventory_files", func(bk *bbolt.Bucket) error {
+		return boltInsert(bk, file.Environment, file)
+	})
+}
+
+func (b *boltBackend) PutInventoryFile(datastore string, file InventoryFile) error {
+	return b.update(datastore, "inventory_files", func(bk *bbolt.Bucket) error {
+		return boltPut(bk, file.Environment, file)
+	})
+}
+
+func (b *boltBackend) DeleteInventoryFile(datastore, envName string) error {
+	return b.update(datastore, "inventory_files", func(bk *bbolt.Bucket) error {
+		return boltDelete(bk, envName)
+	})
+}
+
+func (b *boltBackend) InsertMove(op MoveOperation) error {
+	return b.update(PROVISIONER, JOURNALCOLLECTION, func(bk *bbolt.Bucket) error {
+		return boltInsert(bk, op.Id, op)
+	})
+}
+
+func (b *boltBackend) UpdateMove(op MoveOperation) error {
+	return b.update(PROVISIONER, JOURNALCOLLECTION, func(bk *bbolt.Bucket) error {
+		if bk.Get([]byte(op.Id)) == nil {
+			return ErrNotFound
+		}
+		return boltPut(bk, op.Id, op)
+	})
+}
+
+func (b *boltBackend) UnfinishedMoves() ([]MoveOperation, error) {
+	ops := []MoveOperation{}
+	err := b.view(PROVISIONER, JOURNALCOLLECTION, func(bk *bbolt.Bucket) error {
+		if bk == nil {
+			return nil
+		}
+		return bk.ForEach(func(k, v []byte) error {
+			op := MoveOperation{}
+			if err := json.Unmarshal(v, &op); err != nil {
+				return err
+			}
+			if op.State == MOVECOPYING || op.State == MOVEREMOVING {
+				ops = append(ops, op)
+			}
+			return nil
+		})
+	})
+
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Started.Before(ops[j].Started) })
+	return ops, err
+}
+
+func (b *boltBackend) InsertAudit(record AuditRecord) error {
+	return b.update(PROVISIONER, AUDITCOLLECTION, func(bk *bbolt.Bucket) error {
+		return boltInsert(bk, record.Id, record)
+	})
+}
+
+func (b *boltBackend) AuditRecords(filter AuditFilter) ([]AuditRecord, error) {
+	records := []AuditRecord{}
+	err := b.view(PROVISIONER, AUDITCOLLECTION, func(bk *bbolt.Bucket) error {
+		if bk == nil {
+			return nil
+		}
+		return bk.ForEach(func(k, v []byte) error {
+			record := AuditRecord{}
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if filter.matches(record) {
+				records = append(records, record)
+			}
+			return nil
+		})
+	})
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	return records, err
+}
+
+// adds value to a set kept as a slice unless it is already there
+func addToSet(set []string, value string) []string {
+	for _, v := range set {
+		if v == value {
+			return set
+		}
+	}
+	return append(set, value)
+}
+
+// removes every occurrence of value from a slice
+func pull(set []string, value string) []string {
+	result := make([]string, 0, len(set))
+	for _, v := range set {
+		if v != value {
+			result = append(result, v)
+		}
+	}
+	return result
+}
@@ -0,0 +1,48 @@
+package inventory
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBoltReopen(t *testing.T) {
+	cfg := &Config{Bolt: BoltConfig{Path: filepath.Join(t.TempDir(), "clerk.db")}}
+	backend, err := openBolt(cfg)
+	checkErr(t, err, nil)
+
+	// nothing is read from buckets that were never created
+	_, err = backend.Host(PROVISIONER, testEnv, "web01.example.com")
+	checkErr(t, err, ErrNotFound)
+	groups, err := backend.Groups(PROVISIONER, testEnv)
+	checkErr(t, err, nil)
+	if len(groups) != 0 {
+		t.Errorf("got groups %v from an empty datastore", groups)
+	}
+	checkErr(t, backend.DeleteHost(PROVISIONER, testEnv, "web01.example.com"), ErrNotFound)
+
+	host := AnsibleHost{
+		Fqdn:        "web01.example.com",
+		Environment: testEnv,
+		OsType:      "linux",
+		Groups:      map[string]bool{"web": true},
+		Vars:        map[string]string{"http_port": "8080"},
+	}
+	checkErr(t, backend.InsertHost(PROVISIONER, host), nil)
+	checkErr(t, backend.InsertHost(PROVISIONER, host), ErrAlreadyExists)
+	checkErr(t, backend.Close(), nil)
+
+	// the records are kept in the file
+	backend, err = openBolt(cfg)
+	checkErr(t, err, nil)
+	defer backend.Close()
+	got, err := backend.Host(PROVISIONER, testEnv, host.Fqdn)
+	checkErr(t, err, nil)
+	if !reflect.DeepEqual(got, host) {
+		t.Errorf("reopened host = %+v, want %+v", got, host)
+	}
+
+	// the datastores are kept apart
+	_, err = backend.Host(CUSTODIAN, testEnv, host.Fqdn)
+	checkErr(t, err, ErrNotFound)
+}
@@ -0,0 +1,334 @@
+package inventory
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// default config constants, used for every setting the config file and environment leave empty
+const DEFAULTCONFIG string = "/etc/capernicus/clerk.yml"
+const DEFAULTENV string = "default"
+const DEFAULTMONGOURI string = "mongodb://127.0.0.1"
+const DEFAULTMONGOCONNECTTIMEOUT time.Duration = 10 * time.Second
+const DEFAULTMONGOTIMEOUT time.Duration = time.Minute
+const DEFAULTBOLTPATH string = "/var/lib/capernicus/clerk.db"
+const DEFAULTBACKUPS int = 20
+
+// Backend names
+const MONGOBACKEND string = "mongo"
+const BOLTBACKEND string = "bolt"
+
+// Datastore names
+const PROVISIONER string = "provisioner"
+const CUSTODIAN string = "custodian"
+
+// default database name and inventory root of each datastore
+var defaultDatastores = map[string]DatastoreConfig{
+	PROVISIONER: {Database: "provisioner", InventoryRoot: "/apps/ansible-provisioner-inventories/"},
+	CUSTODIAN:   {Database: "custodian", InventoryRoot: "/apps/ansible-inventories/"},
+}
+
+// Type Definitions
+
+// Config describes how to reach mongo and where the datastores of an installation live
+type Config struct {
+	Environment string                     `yaml:"environment"`
+	Backend     string                     `yaml:"backend"`
+	Mongo       MongoConfig                `yaml:"mongo"`
+	Bolt        BoltConfig                 `yaml:"bolt"`
+	Audit       AuditConfig                `yaml:"audit"`
+	Backups     BackupConfig               `yaml:"backups"`
+	Inventory   InventoryConfig            `yaml:"inventory"`
+	Datastores  map[string]DatastoreConfig `yaml:"datastores"`
+}
+
+type MongoConfig struct {
+	URI            string        `yaml:"uri"`
+	Username       string        `yaml:"username"`
+	Password       string        `yaml:"password"`
+	AuthSource     string        `yaml:"auth_source"`
+	ReplicaSet     string        `yaml:"replica_set"`
+	TLS            bool          `yaml:"tls"`
+	TLSCAFile      string        `yaml:"tls_ca_file"`
+	TLSInsecure    bool          `yaml:"tls_insecure"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+	Timeout        time.Duration `yaml:"timeout"`
+}
+
+type BoltConfig struct {
+	Path string `yaml:"path"`
+}
+
+// AuditConfig names the JSON lines file the audit records are appended to, next to the audit
+// collection. No file is written when it is empty.
+type AuditConfig struct {
+	File string `yaml:"file"`
+}
+
+// BackupConfig sets how many backups of every inventory file are kept, the oldest are
+// removed as new ones are taken
+type BackupConfig struct {
+	Keep int `yaml:"keep"`
+}
+
+// InventoryConfig sets how the inventory files are written. Format is the format of every
+// environment that Environments does not set one for. With LogChanges, clerk prints a summary
+// of the hosts and groups that change every time it rewrites a file.
+type InventoryConfig struct {
+	Format       string                                `yaml:"format"`
+	Environments map[string]EnvironmentInventoryConfig `yaml:"environments"`
+	LogChanges   bool                                  `yaml:"log_changes"`
+}
+
+// EnvironmentInventoryConfig sets how the inventory file of a single environment is written
+type EnvironmentInventoryConfig struct {
+	Format string `yaml:"format"`
+}
+
+type DatastoreConfig struct {
+	Database      string `yaml:"database"`
+	InventoryRoot string `yaml:"inventory_root"`
+}
+
+// LoadConfig loads the configuration file at path, then $CAPERNICUS_CONFIG, then the default location.
+// Only the default location may be missing, the built-in defaults are used in that case.
+func LoadConfig(path string) (*Config, error) {
+	explicit := true
+	if path == "" {
+		path = os.Getenv("CAPERNICUS_CONFIG")
+	}
+	if path == "" {
+		path = DEFAULTCONFIG
+		explicit = false
+	}
+
+	cfg := &Config{}
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		if err = yaml.UnmarshalStrict(data, cfg); err != nil {
+			return nil, fmt.Errorf("invalid configuration file %s: %v", path, err)
+		}
+	case os.IsNotExist(err) && !explicit:
+	default:
+		return nil, err
+	}
+
+	if err = cfg.applyEnv(); err != nil {
+		return nil, err
+	}
+	cfg.applyDefaults()
+
+	if err = cfg.validate(); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// overrides the file settings with the CAPERNICUS_* environment variables
+func (cfg *Config) applyEnv() error {
+	var err error
+
+	overrideString(&cfg.Backend, "CAPERNICUS_BACKEND")
+	overrideString(&cfg.Bolt.Path, "CAPERNICUS_BOLT_PATH")
+	overrideString(&cfg.Audit.File, "CAPERNICUS_AUDIT_FILE")
+	overrideString(&cfg.Inventory.Format, "CAPERNICUS_INVENTORY_FORMAT")
+	overrideString(&cfg.Mongo.URI, "CAPERNICUS_MONGO_URI")
+	overrideString(&cfg.Mongo.Username, "CAPERNICUS_MONGO_USERNAME")
+	overrideString(&cfg.Mongo.Password, "CAPERNICUS_MONGO_PASSWORD")
+	overrideString(&cfg.Mongo.AuthSource, "CAPERNICUS_MONGO_AUTH_SOURCE")
+	overrideString(&cfg.Mongo.ReplicaSet, "CAPERNICUS_MONGO_REPLICA_SET")
+	overrideString(&cfg.Mongo.TLSCAFile, "CAPERNICUS_MONGO_TLS_CA_FILE")
+
+	if err = overrideBool(&cfg.Mongo.TLS, "CAPERNICUS_MONGO_TLS"); err != nil {
+		return err
+	}
+	if err = overrideBool(&cfg.Mongo.TLSInsecure, "CAPERNICUS_MONGO_TLS_INSECURE"); err != nil {
+		return err
+	}
+	if err = overrideDuration(&cfg.Mongo.ConnectTimeout, "CAPERNICUS_MONGO_CONNECT_TIMEOUT"); err != nil {
+		return err
+	}
+	if err = overrideDuration(&cfg.Mongo.Timeout, "CAPERNICUS_MONGO_TIMEOUT"); err != nil {
+		return err
+	}
+	if err = overrideBool(&cfg.Inventory.LogChanges, "CAPERNICUS_INVENTORY_LOG_CHANGES"); err != nil {
+		return err
+	}
+	if err = overrideInt(&cfg.Backups.Keep, "CAPERNICUS_BACKUPS_KEEP"); err != nil {
+		return err
+	}
+
+	if cfg.Datastores == nil {
+		cfg.Datastores = make(map[string]DatastoreConfig)
+	}
+	for name := range defaultDatastores {
+		ds := cfg.Datastores[name]
+		overrideString(&ds.Database, "CAPERNICUS_"+strings.ToUpper(name)+"_DATABASE")
+		overrideString(&ds.InventoryRoot, "CAPERNICUS_"+strings.ToUpper(name)+"_INVENTORY_ROOT")
+		cfg.Datastores[name] = ds
+	}
+
+	return nil
+}
+
+// fills every setting left empty with its built-in default
+func (cfg *Config) applyDefaults() {
+	if cfg.Environment == "" {
+		cfg.Environment = DEFAULTENV
+	}
+
+	if cfg.Backend == "" {
+		cfg.Backend = MONGOBACKEND
+	}
+
+	if cfg.Mongo.URI == "" {
+		cfg.Mongo.URI = DEFAULTMONGOURI
+	}
+	if cfg.Mongo.ConnectTimeout == 0 {
+		cfg.Mongo.ConnectTimeout = DEFAULTMONGOCONNECTTIMEOUT
+	}
+	if cfg.Mongo.Timeout == 0 {
+		cfg.Mongo.Timeout = DEFAULTMONGOTIMEOUT
+	}
+
+	if cfg.Bolt.Path == "" {
+		cfg.Bolt.Path = DEFAULTBOLTPATH
+	}
+
+	if cfg.Inventory.Format == "" {
+		cfg.Inventory.Format = INIFORMAT
+	}
+
+	if cfg.Backups.Keep <= 0 {
+		cfg.Backups.Keep = DEFAULTBACKUPS
+	}
+
+	for name, defaults := range defaultDatastores {
+		ds := cfg.Datastores[name]
+		if ds.Database == "" {
+			ds.Database = defaults.Database
+		}
+		if ds.InventoryRoot == "" {
+			ds.InventoryRoot = defaults.InventoryRoot
+		}
+		if !strings.HasSuffix(ds.InventoryRoot, "/") {
+			ds.InventoryRoot += "/"
+		}
+		cfg.Datastores[name] = ds
+	}
+}
+
+// rejects the settings that have no meaning
+func (cfg *Config) validate() error {
+	if !validInventoryFormat(cfg.Inventory.Format) {
+		return fmt.Errorf("%w: unknown inventory format %q", ErrInvalidConfig, cfg.Inventory.Format)
+	}
+	for envName, envConfig := range cfg.Inventory.Environments {
+		if envConfig.Format != "" && !validInventoryFormat(envConfig.Format) {
+			return fmt.Errorf("%w: unknown inventory format %q of environment %s", ErrInvalidConfig, envConfig.Format, envName)
+		}
+	}
+	return nil
+}
+
+// builds the TLS settings used to connect to mongo
+func (mc MongoConfig) tlsConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: mc.TLSInsecure}
+
+	if mc.TLSCAFile != "" {
+		pem, err := os.ReadFile(mc.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", mc.TLSCAFile)
+		}
+	}
+
+	return tlsConfig, nil
+}
+
+// DatabaseName returns the mongo database that backs a datastore
+func (cfg *Config) DatabaseName(datastore string) string {
+	return cfg.Datastores[datastore].Database
+}
+
+// InventoryRoot returns the directory under which the inventory files of a datastore live
+func (cfg *Config) InventoryRoot(datastore string) string {
+	return cfg.Datastores[datastore].InventoryRoot
+}
+
+// InventoryFormat returns the format the inventory file of an environment is written in
+func (cfg *Config) InventoryFormat(envName string) string {
+	if format := cfg.Inventory.Environments[envName].Format; format != "" {
+		return format
+	}
+	if cfg.Inventory.Format == "" {
+		return INIFORMAT
+	}
+	return cfg.Inventory.Format
+}
+
+// ValidDatastore reports whether datastore names one of the configured datastores
+func (cfg *Config) ValidDatastore(datastore string) bool {
+	_, ok := cfg.Datastores[datastore]
+	return ok
+}
+
+func overrideString(setting *string, envVar string) {
+	if value := os.Getenv(envVar); value != "" {
+		*setting = value
+	}
+}
+
+func overrideBool(setting *bool, envVar string) error {
+	value := os.Getenv(envVar)
+	if value == "" {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("invalid value %q for %s: %v", value, envVar, err)
+	}
+	*setting = b
+	return nil
+}
+
+func overrideInt(setting *int, envVar string) error {
+	value := os.Getenv(envVar)
+	if value == "" {
+		return nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("invalid value %q for %s: %v", value, envVar, err)
+	}
+	*setting = i
+	return nil
+}
+
+func overrideDuration(setting *time.Duration, envVar string) error {
+	value := os.Getenv(envVar)
+	if value == "" {
+		return nil
+	}
+
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return fmt.Errorf("invalid value %q for %s: %v", value, envVar, err)
+	}
+	*setting = d
+	return nil
+}
@@ -0,0 +1,348 @@
+package inventory
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// lines of unchanged context around every hunk of a unified diff
+const DIFFCONTEXT int = 3
+
+// largest table the line matching of UnifiedDiff builds, larger changes are shown as a whole
+// block removed and added instead of line by line
+const maxDiffCells int = 4000000
+
+// a line of a diff: ' ' kept, '-' removed or '+' added
+type diffLine struct {
+	op   byte
+	text string
+}
+
+// UnifiedDiff returns the unified diff between two versions of a file, or an empty string
+// when they are the same
+func UnifiedDiff(fromName, toName string, from, to []byte) string {
+	lines := diffLines(splitLines(string(from)), splitLines(string(to)))
+
+	changed := false
+	for _, line := range lines {
+		if line.op != ' ' {
+			changed = true
+			break
+		}
+	}
+	if !changed {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("--- " + fromName + "\n+++ " + toName + "\n")
+
+	// group the changes into hunks with their surrounding context
+	for start := 0; start < len(lines); {
+		if lines[start].op == ' ' {
+			start++
+			continue
+		}
+
+		first := start - DIFFCONTEXT
+		if first < 0 {
+			first = 0
+		}
+		last, kept := start, 0
+		for end := start; end < len(lines) && kept <= 2*DIFFCONTEXT; end++ {
+			if lines[end].op == ' ' {
+				kept++
+				continue
+			}
+			last, kept = end, 0
+		}
+		last += DIFFCONTEXT
+		if last >= len(lines) {
+			last = len(lines) - 1
+		}
+
+		writeHunk(&b, lines, first, last)
+		start = last + 1
+	}
+
+	return b.String()
+}
+
+// writes the lines first to last of a diff as one hunk
+func writeHunk(b *strings.Builder, lines []diffLine, first, last int) {
+	fromLine, toLine := 1, 1
+	for _, line := range lines[:first] {
+		if line.op != '+' {
+			fromLine++
+		}
+		if line.op != '-' {
+			toLine++
+		}
+	}
+
+	fromCount, toCount := 0, 0
+	for _, line := range lines[first : last+1] {
+		if line.op != '+' {
+			fromCount++
+		}
+		if line.op != '-' {
+			toCount++
+		}
+	}
+
+	// an empty range starts at the line before it
+	if fromCount == 0 {
+		fromLine--
+	}
+	if toCount == 0 {
+		toLine--
+	}
+
+	fmt.Fprintf(b, "@@ -%d,%d +%d,%d @@\n", fromLine, fromCount, toLine, toCount)
+	for _, line := range lines[first : last+1] {
+		b.WriteByte(line.op)
+		b.WriteString(line.text + "\n")
+	}
+}
+
+// splits a file into its lines, a final newline does not start another line
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// matches the lines of two files by their longest common subsequence. The common head and
+// tail are matched first, so the table only covers the part that changed.
+func diffLines(a, b []string) []diffLine {
+	head := 0
+	for head < len(a) && head < len(b) && a[head] == b[head] {
+		head++
+	}
+	tail := 0
+	for tail < len(a)-head && tail < len(b)-head && a[len(a)-1-tail] == b[len(b)-1-tail] {
+		tail++
+	}
+
+	lines := []diffLine{}
+	for _, text := range a[:head] {
+		lines = append(lines, diffLine{' ', text})
+	}
+	lines = append(lines, diffMiddle(a[head:len(a)-tail], b[head:len(b)-tail])...)
+	for _, text := range a[len(a)-tail:] {
+		lines = append(lines, diffLine{' ', text})
+	}
+	return lines
+}
+
+func diffMiddle(a, b []string) []diffLine {
+	lines := []diffLine{}
+	if len(a)*len(b) > maxDiffCells {
+		for _, text := range a {
+			lines = append(lines, diffLine{'-', text})
+		}
+		for _, text := range b {
+			lines = append(lines, diffLine{'+', text})
+		}
+		return lines
+	}
+
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	common := make([][]int32, len(a)+1)
+	for i := range common {
+		common[i] = make([]int32, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i, j = i+1, j+1
+		case common[i+1][j] >= common[i][j+1]:
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		lines = append(lines, diffLine{'-', a[i]})
+	}
+	for ; j < len(b); j++ {
+		lines = append(lines, diffLine{'+', b[j]})
+	}
+	return lines
+}
+
+// InventoryDiff compares the inventory file of an environment as the datastore would write it
+// now with the file on disk or one of its backups
+type InventoryDiff struct {
+	From    string
+	To      string
+	Unified string
+	Changes InventoryChanges
+}
+
+// InventoryChanges summarizes the differences between two versions of an inventory file: the
+// groups added and removed, the groups whose children or variables changed, the hosts that
+// joined or left the inventory, and the members added to and removed from each group
+type InventoryChanges struct {
+	AddedGroups    []string
+	RemovedGroups  []string
+	ChangedGroups  []string
+	AddedHosts     []string
+	RemovedHosts   []string
+	AddedMembers   map[string][]string
+	RemovedMembers map[string][]string
+}
+
+// Empty reports whether there are no changes
+func (c InventoryChanges) Empty() bool {
+	return len(c.AddedGroups) == 0 && len(c.RemovedGroups) == 0 && len(c.ChangedGroups) == 0 &&
+		len(c.AddedHosts) == 0 && len(c.RemovedHosts) == 0 && len(c.AddedMembers) == 0 && len(c.RemovedMembers) == 0
+}
+
+// DiffInventoryFile compares the inventory file of an environment as the datastore would write
+// it now with the file on disk, or with the backup of the timestamp when one is given. A file
+// that does not exist is compared as empty.
+func (s *Store) DiffInventoryFile(envName, datastore, timestamp string) (InventoryDiff, error) {
+	file, err := s.backend.InventoryFile(datastore, envName)
+	if err != nil {
+		return InventoryDiff{}, describe("inventory file of environment", envName, datastore, err)
+	}
+
+	// the recorded file and its backups are still in the old format when the format of the
+	// environment changed since the file was last written
+	path := s.formatPath(file)
+	fromPath := file.Path
+	if timestamp != "" {
+		fromPath = ""
+		backups, err := s.InventoryBackups(envName, datastore)
+		if err != nil {
+			return InventoryDiff{}, err
+		}
+		for _, backup := range backups {
+			if backup.Timestamp == timestamp {
+				fromPath = backup.Path
+			}
+		}
+		if fromPath == "" {
+			return InventoryDiff{}, describe("inventory backup", timestamp, datastore, ErrNotFound)
+		}
+	}
+
+	from, err := os.ReadFile(fromPath)
+	if err != nil && !os.IsNotExist(err) {
+		return InventoryDiff{}, err
+	}
+	to, err := s.renderInventoryFile(envName, datastore)
+	if err != nil {
+		return InventoryDiff{}, err
+	}
+
+	fromGroups, err := parseInventory(pathFormat(file.Path), from)
+	if err != nil {
+		return InventoryDiff{}, fmt.Errorf("reading %s: %w", fromPath, err)
+	}
+	toGroups, err := parseInventory(s.config.InventoryFormat(envName), to)
+	if err != nil {
+		return InventoryDiff{}, err
+	}
+
+	diff := InventoryDiff{From: fromPath, To: path + " (" + datastore + ")"}
+	diff.Unified = UnifiedDiff(diff.From, diff.To, from, to)
+	diff.Changes = inventoryChanges(fromGroups, toGroups)
+	return diff, nil
+}
+
+// summarizes the differences between the groups of two versions of an inventory file
+func inventoryChanges(from, to map[string]*fileGroup) InventoryChanges {
+	changes := InventoryChanges{AddedMembers: make(map[string][]string), RemovedMembers: make(map[string][]string)}
+	fromHosts, toHosts := make(map[string]bool), make(map[string]bool)
+
+	for name, group := range from {
+		for _, member := range group.members {
+			fromHosts[member] = true
+		}
+		if _, ok := to[name]; !ok {
+			changes.RemovedGroups = append(changes.RemovedGroups, name)
+		}
+	}
+
+	for name, group := range to {
+		for _, member := range group.members {
+			toHosts[member] = true
+		}
+
+		old, ok := from[name]
+		if !ok {
+			changes.AddedGroups = append(changes.AddedGroups, name)
+			old = &fileGroup{}
+		} else if !sameSet(old.children, group.children) || !sameSet(old.vars, group.vars) {
+			changes.ChangedGroups = append(changes.ChangedGroups, name)
+		}
+
+		if added := missingFrom(old.members, group.members); len(added) > 0 {
+			changes.AddedMembers[name] = added
+		}
+		if removed := missingFrom(group.members, old.members); len(removed) > 0 {
+			changes.RemovedMembers[name] = removed
+		}
+	}
+
+	// the members of a removed group are removed with it
+	for _, name := range changes.RemovedGroups {
+		if len(from[name].members) > 0 {
+			changes.RemovedMembers[name] = missingFrom(nil, from[name].members)
+		}
+	}
+
+	for host := range toHosts {
+		if !fromHosts[host] {
+			changes.AddedHosts = append(changes.AddedHosts, host)
+		}
+	}
+	for host := range fromHosts {
+		if !toHosts[host] {
+			changes.RemovedHosts = append(changes.RemovedHosts, host)
+		}
+	}
+
+	for _, names := range [][]string{changes.AddedGroups, changes.RemovedGroups, changes.ChangedGroups, changes.AddedHosts, changes.RemovedHosts} {
+		sort.Strings(names)
+	}
+	return changes
+}
+
+// returns the sorted values of b that are not in a
+func missingFrom(a, b []string) []string {
+	missing := []string{}
+	for _, value := range b {
+		if !contains(a, value) && !contains(missing, value) {
+			missing = append(missing, value)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// reports whether two lists hold the same values in any order
+func sameSet(a, b []string) bool {
+	return len(missingFrom(a, b)) == 0 && len(missingFrom(b, a)) == 0
+}
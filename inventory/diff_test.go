@@ -0,0 +1,84 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		from string
+		to   string
+		want string
+	}{
+		{name: "same", from: "a\nb\n", to: "a\nb\n", want: ""},
+		{name: "added", from: "a\nb\n", to: "a\nb\nc\n", want: "--- old\n+++ new\n@@ -1,2 +1,3 @@\n a\n b\n+c\n"},
+		{name: "replaced", from: "a\nb\nc\n", to: "a\nx\nc\n", want: "--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+x\n c\n"},
+		{name: "empty", from: "", to: "a\n", want: "--- old\n+++ new\n@@ -0,0 +1,1 @@\n+a\n"},
+		{
+			name: "two hunks",
+			from: "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			to:   "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n",
+			want: "--- old\n+++ new\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -9,4 +10,3 @@\n 9\n 10\n 11\n-12\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := UnifiedDiff("old", "new", []byte(tt.from), []byte(tt.to)); got != tt.want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiffInventoryFile(t *testing.T) {
+	s := newTestStore(t)
+	checkErr(t, s.CreateInventoryFile(testEnv, PROVISIONER), nil)
+	mustAddHost(t, s, "web01.example.com", PROVISIONER)
+	mustAddGroup(t, s, "web", PROVISIONER)
+	mustAddGroup(t, s, "old", PROVISIONER)
+	mustAttach(t, s, "web01.example.com", "old", PROVISIONER)
+	checkErr(t, s.UpdateInventoryFile(testEnv, PROVISIONER), nil)
+
+	diff, err := s.DiffInventoryFile(testEnv, PROVISIONER, "")
+	checkErr(t, err, nil)
+	if diff.Unified != "" || !diff.Changes.Empty() {
+		t.Fatalf("fresh inventory file differs: %+v", diff)
+	}
+
+	mustAddHost(t, s, "web02.example.com", PROVISIONER)
+	mustAttach(t, s, "web02.example.com", "web", PROVISIONER)
+	checkErr(t, s.DeleteGroup("old", testEnv, PROVISIONER), nil)
+	checkErr(t, s.SetGroupVars("web", testEnv, PROVISIONER, map[string]string{"http_port": "80"}), nil)
+
+	diff, err = s.DiffInventoryFile(testEnv, PROVISIONER, "")
+	checkErr(t, err, nil)
+	want := InventoryChanges{
+		RemovedGroups:  []string{"old"},
+		ChangedGroups:  []string{"web"},
+		AddedHosts:     []string{"web02.example.com"},
+		AddedMembers:   map[string][]string{AllGroup(testEnv): {"web02.example.com"}, "web": {"web02.example.com"}},
+		RemovedMembers: map[string][]string{"old": {"web01.example.com"}},
+	}
+	if !reflect.DeepEqual(diff.Changes, want) {
+		t.Errorf("changes = %+v, want %+v", diff.Changes, want)
+	}
+	if diff.Unified == "" {
+		t.Error("changed inventory file has no unified diff")
+	}
+
+	// a backup is compared instead of the file on disk
+	checkErr(t, s.UpdateInventoryFile(testEnv, PROVISIONER), nil)
+	backups, err := s.InventoryBackups(testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+	diff, err = s.DiffInventoryFile(testEnv, PROVISIONER, backups[len(backups)-1].Timestamp)
+	checkErr(t, err, nil)
+	if !reflect.DeepEqual(diff.Changes, want) {
+		t.Errorf("changes from the backup = %+v, want %+v", diff.Changes, want)
+	}
+
+	_, err = s.DiffInventoryFile(testEnv, PROVISIONER, "20000101T000000.000000Z")
+	checkErr(t, err, ErrNotFound)
+}
@@ -0,0 +1,237 @@
+package inventory
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// Change actions, see Changes
+const CHANGEINSERT string = "insert"
+const CHANGEUPDATE string = "update"
+const CHANGEREMOVE string = "remove"
+
+// Change is a host, group or environment document that differs between two stores. Before
+// is nil for an insert and After is nil for a removal.
+type Change struct {
+	Datastore string
+	Kind      string
+	Name      string
+	Action    string
+	Before    interface{}
+	After     interface{}
+}
+
+// the environments a dry run store copied and the inventory files it would have written
+type dryRunPlan struct {
+	environments []string
+	files        map[string][]byte
+}
+
+// DryRun returns a store on an in-memory copy of the environments of both datastores, together
+// with the environments of the unfinished moves so that they can be resumed. Operations on
+// the copy never reach the datastores, and inventory files are rendered but not written,
+// see PlannedFiles. Compare the copy with the store with Changes.
+func (s *Store) DryRun(envNames ...string) (*Store, error) {
+	ops, err := s.backend.UnfinishedMoves()
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		envNames = append(envNames, op.Environment)
+	}
+
+	plan := NewStore(s.config, NewMemoryBackend())
+	plan.plan = &dryRunPlan{files: make(map[string][]byte)}
+
+	copied := make(map[string]bool)
+	for _, envName := range envNames {
+		if copied[envName] {
+			continue
+		}
+		copied[envName] = true
+		plan.plan.environments = append(plan.plan.environments, envName)
+
+		for _, ds := range []string{PROVISIONER, CUSTODIAN} {
+			if err = s.copyEnvironment(plan.backend, envName, ds); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, op := range ops {
+		if err = plan.backend.InsertMove(op); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
+
+// copies the documents of an environment of a datastore to another backend, an environment
+// missing from the datastore is skipped
+func (s *Store) copyEnvironment(to Backend, envName, datastore string) error {
+	env, err := s.backend.Environment(datastore, envName)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = to.InsertEnvironment(datastore, env); err != nil {
+		return err
+	}
+
+	hosts, err := s.backend.Hosts(datastore, envName, HostFilter{})
+	if err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		if err = to.PutHost(datastore, host); err != nil {
+			return err
+		}
+	}
+
+	groups, err := s.backend.Groups(datastore, envName)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if err = to.InsertGroup(datastore, group); err != nil {
+			return err
+		}
+	}
+
+	file, err := s.backend.InventoryFile(datastore, envName)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return to.InsertInventoryFile(datastore, file)
+}
+
+// PlannedFiles returns the inventory files a dry run store would have written, by path
+func (s *Store) PlannedFiles() map[string][]byte {
+	if s.plan == nil {
+		return nil
+	}
+	return s.plan.files
+}
+
+// Changes lists the host, group and environment documents that differ between a store and
+// its dry run copy, in the environments the copy holds. The changes are ordered by
+// environment, datastore, kind and name.
+func Changes(before, plan *Store) ([]Change, error) {
+	if plan.plan == nil {
+		return nil, fmt.Errorf("the store is not a dry run")
+	}
+
+	changes := []Change{}
+	for _, envName := range plan.plan.environments {
+		for _, ds := range []string{PROVISIONER, CUSTODIAN} {
+			envChanges, err := environmentChanges(before, plan, envName, ds)
+			if err != nil {
+				return nil, err
+			}
+			changes = append(changes, envChanges...)
+		}
+	}
+
+	return changes, nil
+}
+
+// lists the changes of the documents of an environment of a datastore
+func environmentChanges(before, after *Store, envName, datastore string) ([]Change, error) {
+	oldDocs, err := environmentDocuments(before, envName, datastore)
+	if err != nil {
+		return nil, err
+	}
+	newDocs, err := environmentDocuments(after, envName, datastore)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []docKey{}
+	for key := range oldDocs {
+		keys = append(keys, key)
+	}
+	for key := range newDocs {
+		if _, ok := oldDocs[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].kind != keys[j].kind {
+			return keys[i].kind < keys[j].kind
+		}
+		return keys[i].name < keys[j].name
+	})
+
+	changes := []Change{}
+	for _, key := range keys {
+		oldDoc, inOld := oldDocs[key]
+		newDoc, inNew := newDocs[key]
+		change := Change{Datastore: datastore, Kind: key.kind, Name: key.name}
+
+		switch {
+		case !inOld:
+			change.Action, change.After = CHANGEINSERT, newDoc
+		case !inNew:
+			change.Action, change.Before = CHANGEREMOVE, oldDoc
+		case !sameDocument(oldDoc, newDoc):
+			change.Action, change.Before, change.After = CHANGEUPDATE, oldDoc, newDoc
+		default:
+			continue
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, nil
+}
+
+// identifies a document of an environment by its kind and name
+type docKey struct {
+	kind string
+	name string
+}
+
+// returns the environment, host and group documents of an environment of a datastore
+func environmentDocuments(s *Store, envName, datastore string) (map[docKey]interface{}, error) {
+	docs := make(map[docKey]interface{})
+
+	env, err := s.backend.Environment(datastore, envName)
+	if err == ErrNotFound {
+		return docs, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	docs[docKey{"environment", env.Name}] = env
+
+	hosts, err := s.backend.Hosts(datastore, envName, HostFilter{})
+	if err != nil {
+		return nil, err
+	}
+	for _, host := range hosts {
+		docs[docKey{"host", host.Fqdn}] = host
+	}
+
+	groups, err := s.backend.Groups(datastore, envName)
+	if err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		docs[docKey{"group", group.Name}] = group
+	}
+
+	return docs, nil
+}
+
+// compares two documents by their encoding, which does not depend on map order
+func sameDocument(a, b interface{}) bool {
+	aJSON, errA := json.Marshal(a)
+	bJSON, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(aJSON) == string(bJSON)
+}
@@ -0,0 +1,60 @@
+package inventory
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	s := newTestStore(t)
+	for _, ds := range []string{PROVISIONER, CUSTODIAN} {
+		checkErr(t, s.CreateInventoryFile(testEnv, ds), nil)
+	}
+	mustAddHost(t, s, "web01.example.com", PROVISIONER)
+	mustAddGroup(t, s, "web", PROVISIONER)
+	mustAddGroup(t, s, "old", PROVISIONER)
+
+	plan, err := s.DryRun(testEnv)
+	checkErr(t, err, nil)
+
+	mustAddHost(t, plan, "web02.example.com", PROVISIONER)
+	mustAttach(t, plan, "web02.example.com", "web", PROVISIONER)
+	checkErr(t, plan.DeleteGroup("old", testEnv, PROVISIONER), nil)
+	checkErr(t, plan.UpdateInventoryFile(testEnv, PROVISIONER), nil)
+
+	// the store is left alone
+	exists, err := s.HostExists("web02.example.com", testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+	if exists {
+		t.Error("dry run added the host to the store")
+	}
+	checkStrings(t, "old members", members(t, s, "old", PROVISIONER), nil)
+
+	changes, err := Changes(s, plan)
+	checkErr(t, err, nil)
+	got := []string{}
+	for _, change := range changes {
+		got = append(got, change.Action+" "+change.Kind+" "+change.Name+" "+change.Datastore)
+	}
+	want := []string{
+		"update environment " + testEnv + " " + PROVISIONER,
+		"update group " + AllGroup(testEnv) + " " + PROVISIONER,
+		"remove group old " + PROVISIONER,
+		"update group web " + PROVISIONER,
+		"insert host web02.example.com " + PROVISIONER,
+	}
+	checkStrings(t, "changes", got, want)
+
+	// the inventory file is rendered, not written
+	file, err := s.backend.InventoryFile(PROVISIONER, testEnv)
+	checkErr(t, err, nil)
+	content, err := os.ReadFile(file.Path)
+	checkErr(t, err, nil)
+	if string(content) != fileHeader {
+		t.Errorf("dry run wrote the inventory file:\n%s", content)
+	}
+	if !strings.Contains(string(plan.PlannedFiles()[file.Path]), "[web]\nweb02.example.com\n") {
+		t.Errorf("planned inventory file misses the new member:\n%s", plan.PlannedFiles()[file.Path])
+	}
+}
@@ -0,0 +1,94 @@
+package inventory
+
+import (
+	"encoding/json"
+)
+
+// Inventory is the dynamic inventory of an environment as ansible expects it from --list
+type Inventory struct {
+	Groups map[string]AnsibleInventoryGroup
+	Meta   AnsibleHostMeta
+}
+
+// MarshalJSON renders the groups at the top level next to the _meta host variables
+func (inv Inventory) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{}, len(inv.Groups)+1)
+	for name, group := range inv.Groups {
+		out[name] = group
+	}
+	out["_meta"] = inv.Meta
+
+	return json.Marshal(out)
+}
+
+// EnvExists reports whether an environment exists in a datastore
+func (s *Store) EnvExists(envName, datastore string) (bool, error) {
+	_, err := s.backend.Environment(datastore, envName)
+	if err == ErrNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// AddEnvironment adds an environment together with its default group
+func (s *Store) AddEnvironment(newEnv *AnsibleEnvironment, datastore string) error {
+	a := s.startAudit(AUDITADDENVIRONMENT, newEnv.Name, []string{datastore}, nil, []string{AllGroup(newEnv.Name)})
+	return a.finish(s.addEnvironment(newEnv, datastore))
+}
+
+func (s *Store) addEnvironment(newEnv *AnsibleEnvironment, datastore string) error {
+	if newEnv.Prefix == "" {
+		newEnv.Prefix = EnvPrefix(newEnv.Name)
+	}
+	if newEnv.Groups == nil {
+		newEnv.Groups = make(map[string]bool)
+	}
+
+	err := s.backend.InsertEnvironment(datastore, *newEnv)
+	if err != nil {
+		return envError(newEnv.Name, datastore, err)
+	}
+
+	// every host of the environment is attached to its default group
+	allGroup := AnsibleGroups{}
+	allGroup.Name = AllGroup(newEnv.Name)
+	allGroup.Members = map[string][]string{allGroup.Name: make([]string, 0)}
+	allGroup.Description = "Default Group for all members in " + newEnv.Name
+	allGroup.Environment = newEnv.Name
+	allGroup.Vars = make(map[string]string)
+	allGroup.Children = make([]string, 0)
+
+	return s.addGroup(allGroup, datastore)
+}
+
+// Inventory returns the groups of an environment together with the variables of every host
+func (s *Store) Inventory(envName, datastore string) (Inventory, error) {
+	inventory := Inventory{
+		Groups: make(map[string]AnsibleInventoryGroup),
+		Meta:   AnsibleHostMeta{HostVars: make(map[string]map[string]string)},
+	}
+
+	groups, err := s.Groups(envName, datastore)
+	if err != nil {
+		return inventory, err
+	}
+	for _, ansibleGrp := range groups {
+		invGroup := AnsibleInventoryGroup{Hosts: ansibleGrp.Members[ansibleGrp.Name], Vars: ansibleGrp.Vars, Children: ansibleGrp.Children}
+		if invGroup.Hosts == nil {
+			invGroup.Hosts = make([]string, 0)
+		}
+		inventory.Groups[ansibleGrp.Name] = invGroup
+	}
+
+	// collect the variables of every host so that ansible does not need to call --host per host
+	hosts, err := s.Hosts(envName, datastore, HostFilter{})
+	if err != nil {
+		return inventory, err
+	}
+	for _, ansibleHost := range hosts {
+		inventory.Meta.HostVars[ansibleHost.Fqdn] = HostVarMap(ansibleHost)
+	}
+
+	return inventory, nil
+}
@@ -0,0 +1,71 @@
+package inventory
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors reported by the store, test for them with errors.Is
+var (
+	ErrNotFound      = errors.New("does not exist")
+	ErrAlreadyExists = errors.New("already exists")
+	ErrInvalidName   = errors.New("is not a valid name")
+	ErrCycle         = errors.New("would create a cycle")
+	ErrInUse         = errors.New("is still in use")
+	ErrUnfinished    = errors.New("is unfinished")
+	ErrMalformed     = errors.New("is not in the form key=value")
+
+	// the datastore could not be reached or did not answer in time, the operation may succeed
+	// when retried
+	ErrUnavailable = errors.New("datastore is unavailable")
+
+	// the configuration names an unknown backend or settings that cannot be used
+	ErrInvalidConfig = errors.New("invalid configuration")
+)
+
+// Error describes what an operation failed on: a host, group, environment or inventory file
+// of a datastore. Err is one of the errors above or the error of the underlying datastore.
+type Error struct {
+	Kind      string
+	Name      string
+	Datastore string
+	Err       error
+}
+
+func (e *Error) Error() string {
+	if e.Datastore == "" {
+		return fmt.Sprintf("the %s %s %v", e.Kind, e.Name, e.Err)
+	}
+	return fmt.Sprintf("the %s %s %v in datastore %s", e.Kind, e.Name, e.Err, e.Datastore)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// describes the errors above with what they were reported for, other errors such as the
+// failures of a backend are returned as they are
+func describe(kind, name, datastore string, err error) error {
+	switch err {
+	case ErrNotFound, ErrAlreadyExists, ErrInvalidName, ErrCycle, ErrInUse, ErrUnfinished, ErrMalformed:
+		return &Error{Kind: kind, Name: name, Datastore: datastore, Err: err}
+	}
+	return err
+}
+
+// marks a failure to reach a datastore, such as a lost connection or a timeout
+func unavailable(err error) error {
+	return fmt.Errorf("%w: %v", ErrUnavailable, err)
+}
+
+func hostError(name, datastore string, err error) error {
+	return describe("host", name, datastore, err)
+}
+
+func groupError(name, datastore string, err error) error {
+	return describe("group", name, datastore, err)
+}
+
+func envError(name, datastore string, err error) error {
+	return describe("environment", name, datastore, err)
+}
@@ -0,0 +1,173 @@
+package inventory
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// header written at the top of every generated inventory file
+const fileHeader string = "# -- !!! WARNING !!! -- This File is managed by provisioner, any changes will be over-written\n# on the next provisioner run.\n#\n#\n"
+
+// CreateInventoryFile records the inventory file of an environment and creates it, together
+// with its directory and backup directory, under the inventory root of the datastore. The file
+// is written in the format configured for the environment, see Config.InventoryFormat. When the
+// file cannot be created the record is removed again, so that the creation can be retried.
+func (s *Store) CreateInventoryFile(envName, datastore string) error {
+	envDir := EnvPrefix(envName)
+	inventoryDir := s.config.InventoryRoot(datastore) + envDir
+	invFile := InventoryFile{Path: inventoryDir + "/" + envDir + ".inventory", Environment: envName}
+	invFile.Path = s.formatPath(invFile)
+
+	err := s.backend.InsertInventoryFile(datastore, invFile)
+	if err != nil {
+		return describe("inventory file of environment", envName, datastore, err)
+	}
+
+	err = s.writeNewInventoryFile(invFile, inventoryDir)
+	if err != nil {
+		if deleteErr := s.backend.DeleteInventoryFile(datastore, envName); deleteErr != nil {
+			return fmt.Errorf("%w, and its record was not removed: %v", err, deleteErr)
+		}
+	}
+	return err
+}
+
+// writes the first inventory file of an environment, creating its directory and backup
+// directory. The directories of an earlier attempt that failed are used as they are, but a file
+// that is already there is left alone.
+func (s *Store) writeNewInventoryFile(invFile InventoryFile, inventoryDir string) error {
+	content, err := renderInventory(s.config.InventoryFormat(invFile.Environment), nil, nil)
+	if err != nil {
+		return err
+	}
+
+	if s.plan != nil {
+		s.plan.files[invFile.Path] = content
+		return nil
+	}
+
+	// create environment inventory file directory and its backup directory
+	if err = os.Mkdir(inventoryDir, 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	if err = os.Mkdir(inventoryDir+"/backups", 0755); err != nil && !os.IsExist(err) {
+		return err
+	}
+	if _, err = os.Stat(invFile.Path); err == nil {
+		return describe("inventory file", invFile.Path, "", ErrAlreadyExists)
+	}
+
+	return writeFileAtomic(invFile.Path, content)
+}
+
+// UpdateInventoryFile writes the inventory file of an environment again from the datastore. The
+// file it replaces is kept as a backup, see InventoryBackups, and the new file takes its place
+// in a single rename so ansible never reads a missing or partly written inventory. When the
+// format of the environment changed, the file is written under its new name, recorded in place
+// of the old one, and the file in the old format is moved into the backups.
+func (s *Store) UpdateInventoryFile(envName, datastore string) error {
+	resultFile, err := s.backend.InventoryFile(datastore, envName)
+	if err != nil {
+		return describe("inventory file of environment", envName, datastore, err)
+	}
+
+	content, err := s.renderInventoryFile(envName, datastore)
+	if err != nil {
+		return err
+	}
+
+	path := s.formatPath(resultFile)
+	if path == resultFile.Path {
+		return s.replaceInventoryFile(resultFile, datastore, content)
+	}
+	return s.convertInventoryFile(resultFile, datastore, path, content)
+}
+
+// backs up the inventory file of an environment and replaces it with content, an unchanged
+// file is left alone
+func (s *Store) replaceInventoryFile(file InventoryFile, datastore string, content []byte) error {
+	if s.plan != nil {
+		s.plan.files[file.Path] = content
+		return nil
+	}
+
+	current, err := os.ReadFile(file.Path)
+	switch {
+	case err == nil && bytes.Equal(current, content):
+		return nil
+	case err == nil:
+		if err = s.backupInventoryFile(file, datastore, current); err != nil {
+			return err
+		}
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	return writeFileAtomic(file.Path, content)
+}
+
+// writes the inventory file of an environment whose format changed to its new path and records
+// it there. Only then is the file in the old format backed up and removed, so that ansible is
+// never left without an inventory.
+func (s *Store) convertInventoryFile(file InventoryFile, datastore, path string, content []byte) error {
+	converted := InventoryFile{Path: path, Environment: file.Environment}
+	if err := s.replaceInventoryFile(converted, datastore, content); err != nil {
+		return err
+	}
+	if err := s.backend.PutInventoryFile(datastore, converted); err != nil {
+		return describe("inventory file of environment", file.Environment, datastore, err)
+	}
+	if s.plan != nil {
+		return nil
+	}
+
+	current, err := os.ReadFile(file.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = s.backupInventoryFile(file, datastore, current); err != nil {
+		return err
+	}
+	return os.Remove(file.Path)
+}
+
+// writes a file through a temporary file in the same directory that is synced and then
+// renamed over it, so that readers see either the old or the new file in full
+func writeFileAtomic(path string, content []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+
+	_, err = f.Write(content)
+	if err == nil {
+		err = f.Chmod(0644)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	// make the rename itself durable
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,286 @@
+package inventory
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"path/filepath"
+	"strings"
+)
+
+// Inventory file formats, see Config.InventoryFormat
+const INIFORMAT string = "ini"
+const YAMLFORMAT string = "yaml"
+const JSONFORMAT string = "json"
+
+// reports whether format names a known inventory file format
+func validInventoryFormat(format string) bool {
+	switch format {
+	case INIFORMAT, YAMLFORMAT, JSONFORMAT:
+		return true
+	}
+	return false
+}
+
+// returns where the inventory file of an environment is written in the format configured for
+// it. The INI file is named .inventory, the yaml and json files take the extension ansible looks
+// for instead.
+func (s *Store) formatPath(file InventoryFile) string {
+	base := strings.TrimSuffix(file.Path, filepath.Ext(file.Path))
+	switch s.config.InventoryFormat(file.Environment) {
+	case YAMLFORMAT:
+		return base + ".yml"
+	case JSONFORMAT:
+		return base + ".json"
+	}
+	return base + ".inventory"
+}
+
+// returns the format an inventory file was written in from its extension
+func pathFormat(path string) string {
+	switch filepath.Ext(path) {
+	case ".yml":
+		return YAMLFORMAT
+	case ".json":
+		return JSONFORMAT
+	}
+	return INIFORMAT
+}
+
+// renders the inventory file of an environment from the datastore
+func (s *Store) renderInventoryFile(envName, datastore string) ([]byte, error) {
+	format := s.config.InventoryFormat(envName)
+
+	groups, err := s.Groups(envName, datastore)
+	if err != nil {
+		return nil, err
+	}
+
+	// only the yaml and json formats carry the variables of the hosts
+	hosts := []AnsibleHost{}
+	if format != INIFORMAT {
+		if hosts, err = s.Hosts(envName, datastore, HostFilter{}); err != nil {
+			return nil, err
+		}
+	}
+
+	return renderInventory(format, groups, hosts)
+}
+
+// renders an inventory file in a format. The yaml file lists the group descriptions in comments
+// below the header, the json format has no comments and leaves them out.
+func renderInventory(format string, groups []AnsibleGroups, hosts []AnsibleHost) ([]byte, error) {
+	switch format {
+	case INIFORMAT:
+		return renderINI(groups), nil
+	case YAMLFORMAT:
+		b, err := yaml.Marshal(newStructuredInventory(groups, hosts))
+		if err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		buf.WriteString(fileHeader)
+		for _, ansibleGrp := range groups {
+			if ansibleGrp.Description != "" {
+				buf.WriteString("# " + ansibleGrp.Name + ": " + ansibleGrp.Description + "\n")
+			}
+		}
+		buf.Write(b)
+		return buf.Bytes(), nil
+	case JSONFORMAT:
+		b, err := json.MarshalIndent(newStructuredInventory(groups, hosts), "", "    ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	}
+	return nil, fmt.Errorf("%w: unknown inventory format %q", ErrInvalidConfig, format)
+}
+
+// renders an inventory file in the INI format, with the description of every group as a comment.
+// The groups come sorted from Groups and the variables are written sorted, so that an unchanged
+// environment renders to the same bytes.
+func renderINI(groups []AnsibleGroups) []byte {
+	var buf bytes.Buffer
+	buf.WriteString(fileHeader)
+	for _, ansibleGrp := range groups {
+		// write Group Description as Comment
+		buf.WriteString("# " + ansibleGrp.Description + "\n")
+		buf.WriteString("[" + ansibleGrp.Name + "]\n")
+		for _, member := range ansibleGrp.Members[ansibleGrp.Name] {
+			buf.WriteString(member + "\n")
+		}
+
+		// write the nested child groups of the group
+		if len(ansibleGrp.Children) > 0 {
+			buf.WriteString("\n[" + ansibleGrp.Name + ":children]\n")
+			for _, childName := range ansibleGrp.Children {
+				buf.WriteString(childName + "\n")
+			}
+		}
+
+		// write the group variables
+		if len(ansibleGrp.Vars) > 0 {
+			buf.WriteString("\n[" + ansibleGrp.Name + ":vars]\n")
+			for _, k := range varNames(ansibleGrp.Vars) {
+				buf.WriteString(k + "=" + ansibleGrp.Vars[k] + "\n")
+			}
+		}
+
+		buf.WriteString("\n\n\n")
+	}
+
+	return buf.Bytes()
+}
+
+// structuredInventory is an inventory in the YAML inventory format of ansible, which ansible
+// reads from json files as well. Every host is listed with its variables under all, and every
+// group with its members, variables and children under the children of all.
+type structuredInventory struct {
+	All structuredGroup `json:"all" yaml:"all"`
+}
+
+type structuredGroup struct {
+	Hosts    map[string]map[string]string `json:"hosts,omitempty" yaml:"hosts,omitempty"`
+	Vars     map[string]string            `json:"vars,omitempty" yaml:"vars,omitempty"`
+	Children map[string]*structuredGroup  `json:"children,omitempty" yaml:"children,omitempty"`
+}
+
+func newStructuredInventory(groups []AnsibleGroups, hosts []AnsibleHost) structuredInventory {
+	inv := structuredInventory{}
+	if len(hosts) > 0 {
+		inv.All.Hosts = make(map[string]map[string]string)
+	}
+	for _, ansibleHost := range hosts {
+		inv.All.Hosts[ansibleHost.Fqdn] = HostVarMap(ansibleHost)
+	}
+
+	if len(groups) > 0 {
+		inv.All.Children = make(map[string]*structuredGroup)
+	}
+	for _, ansibleGrp := range groups {
+		group := &structuredGroup{}
+		if len(ansibleGrp.Vars) > 0 {
+			group.Vars = ansibleGrp.Vars
+		}
+
+		// the members of a group are listed by name, their variables are under all
+		for _, member := range ansibleGrp.Members[ansibleGrp.Name] {
+			if group.Hosts == nil {
+				group.Hosts = make(map[string]map[string]string)
+			}
+			group.Hosts[member] = nil
+		}
+
+		// the children are defined under all as well and only named here
+		for _, childName := range ansibleGrp.Children {
+			if group.Children == nil {
+				group.Children = make(map[string]*structuredGroup)
+			}
+			group.Children[childName] = &structuredGroup{}
+		}
+
+		inv.All.Children[ansibleGrp.Name] = group
+	}
+
+	return inv
+}
+
+// a group of an inventory file as it is written, its variables as key=value lines
+type fileGroup struct {
+	members  []string
+	children []string
+	vars     []string
+}
+
+// reads the groups of an inventory file in a format, keyed by name
+func parseInventory(format string, content []byte) (map[string]*fileGroup, error) {
+	groups := make(map[string]*fileGroup)
+	inv := structuredInventory{}
+
+	switch format {
+	case INIFORMAT:
+		return parseINI(content), nil
+	case YAMLFORMAT:
+		if err := yaml.Unmarshal(content, &inv); err != nil {
+			return nil, err
+		}
+	case JSONFORMAT:
+		if len(bytes.TrimSpace(content)) == 0 {
+			return groups, nil
+		}
+		if err := json.Unmarshal(content, &inv); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown inventory format %q", ErrInvalidConfig, format)
+	}
+
+	addStructuredGroups(groups, inv.All.Children)
+	return groups, nil
+}
+
+// adds the groups of a structured inventory and the groups nested in them, a group that is
+// defined in several places is merged
+func addStructuredGroups(groups map[string]*fileGroup, children map[string]*structuredGroup) {
+	for name, group := range children {
+		if groups[name] == nil {
+			groups[name] = &fileGroup{}
+		}
+		if group == nil {
+			continue
+		}
+
+		result := groups[name]
+		for member := range group.Hosts {
+			result.members = addToSet(result.members, member)
+		}
+		for childName := range group.Children {
+			result.children = addToSet(result.children, childName)
+		}
+		for k, v := range group.Vars {
+			result.vars = addToSet(result.vars, k+"="+v)
+		}
+
+		addStructuredGroups(groups, group.Children)
+	}
+}
+
+// reads the groups of an inventory file in the INI format
+func parseINI(content []byte) map[string]*fileGroup {
+	groups := make(map[string]*fileGroup)
+	var current *fileGroup
+	section := ""
+
+	for _, line := range splitLines(string(content)) {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.TrimSuffix(strings.TrimPrefix(line, "["), "]")
+			name, section, _ = strings.Cut(name, ":")
+			if groups[name] == nil {
+				groups[name] = &fileGroup{}
+			}
+			current = groups[name]
+			continue
+		}
+		if current == nil {
+			continue
+		}
+
+		switch section {
+		case "":
+			current.members = append(current.members, strings.Fields(line)[0])
+		case "children":
+			current.children = append(current.children, line)
+		case "vars":
+			current.vars = append(current.vars, line)
+		}
+	}
+
+	return groups
+}
@@ -0,0 +1,194 @@
+package inventory
+
+import (
+	"encoding/json"
+	"gopkg.in/yaml.v2"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestInventoryFormats(t *testing.T) {
+	tests := []struct {
+		format    string
+		extension string
+		decode    func([]byte, interface{}) error
+	}{
+		{format: INIFORMAT, extension: ".inventory"},
+		{format: YAMLFORMAT, extension: ".yml", decode: yaml.Unmarshal},
+		{format: JSONFORMAT, extension: ".json", decode: json.Unmarshal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			s := newTestStore(t)
+			s.config.Inventory.Environments = map[string]EnvironmentInventoryConfig{testEnv: {Format: tt.format}}
+			checkErr(t, s.CreateInventoryFile(testEnv, PROVISIONER), nil)
+			mustAddHost(t, s, "web01.example.com", PROVISIONER)
+			checkErr(t, s.SetHostVars("web01.example.com", testEnv, PROVISIONER, map[string]string{"http_port": "8080"}), nil)
+			mustAddGroup(t, s, "web", PROVISIONER)
+			mustAddGroup(t, s, "frontend", PROVISIONER)
+			mustAttach(t, s, "web01.example.com", "web", PROVISIONER)
+			checkErr(t, s.AddChildGroup("frontend", "web", testEnv, PROVISIONER), nil)
+			checkErr(t, s.SetGroupVars("web", testEnv, PROVISIONER, map[string]string{"tier": "1"}), nil)
+			checkErr(t, s.UpdateInventoryFile(testEnv, PROVISIONER), nil)
+
+			file, err := s.backend.InventoryFile(PROVISIONER, testEnv)
+			checkErr(t, err, nil)
+			path := file.Path
+			if filepath.Ext(path) != tt.extension {
+				t.Fatalf("inventory file %s, want extension %s", path, tt.extension)
+			}
+			content, err := os.ReadFile(path)
+			checkErr(t, err, nil)
+
+			groups, err := parseInventory(tt.format, content)
+			checkErr(t, err, nil)
+			checkStrings(t, "web members", groups["web"].members, []string{"web01.example.com"})
+			checkStrings(t, "web vars", groups["web"].vars, []string{"tier=1"})
+			checkStrings(t, "frontend children", groups["frontend"].children, []string{"web"})
+
+			// the structured formats carry the variables of the hosts
+			if tt.decode != nil {
+				inv := structuredInventory{}
+				checkErr(t, tt.decode(content, &inv), nil)
+				if got := inv.All.Hosts["web01.example.com"]["http_port"]; got != "8080" {
+					t.Errorf("host variable http_port = %q, want 8080", got)
+				}
+			}
+
+			// an unchanged environment is written the same way again
+			diff, err := s.DiffInventoryFile(testEnv, PROVISIONER, "")
+			checkErr(t, err, nil)
+			if !diff.Changes.Empty() {
+				t.Errorf("rewritten inventory file differs: %+v", diff.Changes)
+			}
+		})
+	}
+}
+
+func TestInventoryFormatChange(t *testing.T) {
+	s := newTestStore(t)
+	checkErr(t, s.CreateInventoryFile(testEnv, PROVISIONER), nil)
+	mustAddHost(t, s, "web01.example.com", PROVISIONER)
+	checkErr(t, s.AddGroup(AnsibleGroups{Name: "web", Environment: testEnv, Description: "Web servers"}, PROVISIONER), nil)
+	checkErr(t, s.UpdateInventoryFile(testEnv, PROVISIONER), nil)
+	file, err := s.backend.InventoryFile(PROVISIONER, testEnv)
+	checkErr(t, err, nil)
+	iniPath := file.Path
+	dir := filepath.Dir(iniPath)
+
+	// a dry run writes the file in the new format but leaves the old one in place
+	s.config.Inventory.Format = YAMLFORMAT
+	plan, err := s.DryRun(testEnv)
+	checkErr(t, err, nil)
+	checkErr(t, plan.UpdateInventoryFile(testEnv, PROVISIONER), nil)
+	yamlPath := strings.TrimSuffix(iniPath, ".inventory") + ".yml"
+	if _, ok := plan.PlannedFiles()[yamlPath]; !ok {
+		t.Errorf("dry run planned %v, want %s", plan.PlannedFiles(), yamlPath)
+	}
+	if _, err = os.Stat(iniPath); err != nil {
+		t.Errorf("dry run touched %s: %v", iniPath, err)
+	}
+
+	for _, tt := range []struct {
+		format string
+		path   string
+		old    string
+	}{
+		{format: YAMLFORMAT, path: yamlPath, old: iniPath},
+		{format: JSONFORMAT, path: strings.TrimSuffix(iniPath, ".inventory") + ".json", old: yamlPath},
+	} {
+		s.config.Inventory.Format = tt.format
+		checkErr(t, s.UpdateInventoryFile(testEnv, PROVISIONER), nil)
+
+		// the new file is recorded and the old one moved into the backups
+		file, err = s.backend.InventoryFile(PROVISIONER, testEnv)
+		checkErr(t, err, nil)
+		if file.Path != tt.path {
+			t.Errorf("recorded inventory file %s, want %s", file.Path, tt.path)
+		}
+		if _, err = os.Stat(tt.old); !os.IsNotExist(err) {
+			t.Errorf("%s file %s left in place: %v", tt.format, tt.old, err)
+		}
+		backups, err := filepath.Glob(filepath.Join(dir, "backups", filepath.Base(tt.old)+".*"))
+		checkErr(t, err, nil)
+		if len(backups) == 0 {
+			t.Errorf("%s was not backed up", tt.old)
+		}
+
+		content, err := os.ReadFile(tt.path)
+		checkErr(t, err, nil)
+		groups, err := parseInventory(tt.format, content)
+		checkErr(t, err, nil)
+		checkStrings(t, "web members", groups[AllGroup(testEnv)].members, []string{"web01.example.com"})
+		if tt.format == YAMLFORMAT && !strings.Contains(string(content), "# web: Web servers\n") {
+			t.Errorf("yaml file does not describe the groups:\n%s", content)
+		}
+
+		diff, err := s.DiffInventoryFile(testEnv, PROVISIONER, "")
+		checkErr(t, err, nil)
+		if !diff.Changes.Empty() {
+			t.Errorf("converted inventory file differs: %+v", diff.Changes)
+		}
+	}
+}
+
+func TestInventoryOrder(t *testing.T) {
+	s := newTestStore(t)
+	checkErr(t, s.CreateInventoryFile(testEnv, PROVISIONER), nil)
+	for _, host := range []string{"web02.example.com", "db01.example.com", "web01.example.com"} {
+		mustAddHost(t, s, host, PROVISIONER)
+	}
+	for _, group := range []string{"web", "db", "app"} {
+		mustAddGroup(t, s, group, PROVISIONER)
+	}
+	mustAttach(t, s, "web02.example.com", "web", PROVISIONER)
+	mustAttach(t, s, "web01.example.com", "web", PROVISIONER)
+	checkErr(t, s.AddChildGroup("app", "web", testEnv, PROVISIONER), nil)
+	checkErr(t, s.AddChildGroup("app", "db", testEnv, PROVISIONER), nil)
+	checkErr(t, s.SetGroupVars("web", testEnv, PROVISIONER, map[string]string{"tier": "1", "port": "80", "env": "dev"}), nil)
+
+	groups, err := s.Groups(testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+	names := []string{}
+	for _, ansibleGrp := range groups {
+		names = append(names, ansibleGrp.Name)
+	}
+	checkOrder(t, "groups", names, []string{"app", "db", AllGroup(testEnv), "web"})
+
+	inv, err := s.Inventory(testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+	checkOrder(t, "web members", inv.Groups["web"].Hosts, []string{"web01.example.com", "web02.example.com"})
+	checkOrder(t, "app children", inv.Groups["app"].Children, []string{"db", "web"})
+
+	// every format renders an unchanged environment to the same bytes
+	for _, format := range []string{INIFORMAT, YAMLFORMAT, JSONFORMAT} {
+		s.config.Inventory.Format = format
+		first, err := s.renderInventoryFile(testEnv, PROVISIONER)
+		checkErr(t, err, nil)
+		for i := 0; i < 5; i++ {
+			again, err := s.renderInventoryFile(testEnv, PROVISIONER)
+			checkErr(t, err, nil)
+			if string(again) != string(first) {
+				t.Fatalf("%s inventory rendered\n%s\nthen\n%s", format, first, again)
+			}
+		}
+	}
+
+	s.config.Inventory.Format = INIFORMAT
+	content, err := s.renderInventoryFile(testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+	if !strings.Contains(string(content), "[web:vars]\nenv=dev\nport=80\ntier=1\n") {
+		t.Errorf("group variables not written sorted:\n%s", content)
+	}
+}
+
+// compares two lists including their order
+func checkOrder(t *testing.T, what string, got, want []string) {
+	t.Helper()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
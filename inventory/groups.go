@@ -0,0 +1,247 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+)
+
+// GroupExists reports whether a group exists in an environment of a datastore
+func (s *Store) GroupExists(groupName, envName, datastore string) (bool, error) {
+	_, err := s.backend.Group(datastore, envName, groupName)
+	if err == ErrNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Group returns a group of an environment
+func (s *Store) Group(groupName, envName, datastore string) (AnsibleGroups, error) {
+	result, err := s.backend.Group(datastore, envName, groupName)
+	sortGroup(result)
+	return result, groupError(groupName, datastore, err)
+}
+
+// Groups returns every group of an environment, sorted by name
+func (s *Store) Groups(envName, datastore string) ([]AnsibleGroups, error) {
+	result, err := s.backend.Groups(datastore, envName)
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	for _, ansibleGrp := range result {
+		sortGroup(ansibleGrp)
+	}
+	return result, err
+}
+
+// sorts the members and children of a group, the datastores keep them in the order they were added
+func sortGroup(ansibleGrp AnsibleGroups) {
+	for _, members := range ansibleGrp.Members {
+		sort.Strings(members)
+	}
+	sort.Strings(ansibleGrp.Children)
+}
+
+// AddGroup adds a group to its environment
+func (s *Store) AddGroup(newGroup AnsibleGroups, datastore string) error {
+	a := s.startAudit(AUDITADDGROUP, newGroup.Environment, []string{datastore}, nil, []string{newGroup.Name})
+	if !ValidInventoryName(newGroup.Name) {
+		return a.finish(groupError(newGroup.Name, "", ErrInvalidName))
+	}
+	return a.finish(s.addGroup(newGroup, datastore))
+}
+
+func (s *Store) addGroup(newGroup AnsibleGroups, datastore string) error {
+	// group names are used as field names by the membership updates
+	if !ValidGroupName(newGroup.Name) {
+		return groupError(newGroup.Name, "", ErrInvalidName)
+	}
+
+	exists, err := s.EnvExists(newGroup.Environment, datastore)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return envError(newGroup.Environment, datastore, ErrNotFound)
+	}
+
+	if newGroup.Members == nil {
+		newGroup.Members = map[string][]string{newGroup.Name: make([]string, 0)}
+	}
+	if newGroup.Vars == nil {
+		newGroup.Vars = make(map[string]string)
+	}
+	if newGroup.Children == nil {
+		newGroup.Children = make([]string, 0)
+	}
+
+	err = s.backend.InsertGroup(datastore, newGroup)
+	if err != nil {
+		return groupError(newGroup.Name, datastore, err)
+	}
+
+	err = s.backend.SetEnvironmentGroup(datastore, newGroup.Environment, newGroup.Name)
+	return envError(newGroup.Environment, datastore, err)
+}
+
+// DeleteGroup removes a group from its hosts, its parent groups and its environment. The default
+// group of an environment can only be deleted once the environment itself is gone.
+func (s *Store) DeleteGroup(groupName, envName, datastore string) error {
+	a := s.startAudit(AUDITDELETEGROUP, envName, []string{datastore}, nil, []string{groupName})
+	return a.finish(s.deleteGroup(groupName, envName, datastore))
+}
+
+func (s *Store) deleteGroup(groupName, envName, datastore string) error {
+	if groupName == AllGroup(envName) {
+		exists, err := s.EnvExists(envName, datastore)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return groupError(groupName, datastore, ErrInUse)
+		}
+	}
+
+	exists, err := s.GroupExists(groupName, envName, datastore)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return groupError(groupName, datastore, ErrNotFound)
+	}
+
+	if err = s.backend.UnsetGroupFromHosts(datastore, envName, groupName); err != nil {
+		return fmt.Errorf("detaching group %s from its hosts: %w", groupName, err)
+	}
+
+	// a deleted group can no longer be the child of another group
+	if err = s.backend.RemoveChildFromGroups(datastore, envName, groupName); err != nil {
+		return fmt.Errorf("removing group %s from its parent groups: %w", groupName, err)
+	}
+
+	err = s.backend.UnsetEnvironmentGroup(datastore, envName, groupName)
+	if err != nil && err != ErrNotFound {
+		return fmt.Errorf("removing group %s from environment %s: %w", groupName, envName, err)
+	}
+
+	err = s.backend.DeleteGroup(datastore, envName, groupName)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	return nil
+}
+
+// SetGroupVars sets variables on a group, variables that are not supplied are left untouched
+func (s *Store) SetGroupVars(groupName, envName, datastore string, varMap map[string]string) error {
+	a := s.startAudit(AUDITSETGROUPVARS, envName, []string{datastore}, nil, []string{groupName})
+	return a.finish(s.setGroupVars(groupName, envName, datastore, varMap))
+}
+
+func (s *Store) setGroupVars(groupName, envName, datastore string, varMap map[string]string) error {
+	if err := checkVarNames(varNames(varMap)); err != nil {
+		return err
+	}
+	if len(varMap) == 0 {
+		return nil
+	}
+
+	err := s.backend.SetGroupVars(datastore, envName, groupName, varMap)
+	return groupError(groupName, datastore, err)
+}
+
+// UnsetGroupVars removes variables from a group
+func (s *Store) UnsetGroupVars(groupName, envName, datastore string, keys []string) error {
+	a := s.startAudit(AUDITUNSETGROUPVARS, envName, []string{datastore}, nil, []string{groupName})
+	return a.finish(s.unsetGroupVars(groupName, envName, datastore, keys))
+}
+
+func (s *Store) unsetGroupVars(groupName, envName, datastore string, keys []string) error {
+	names := trimVarNames(keys)
+	if err := checkVarNames(names); err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	err := s.backend.UnsetGroupVars(datastore, envName, groupName, names)
+	return groupError(groupName, datastore, err)
+}
+
+// AddChildGroup nests a group under a parent group. Ansible refuses to load an inventory
+// in which a group is its own ancestor, so such a nesting is refused with ErrCycle.
+func (s *Store) AddChildGroup(parentName, childName, envName, datastore string) error {
+	a := s.startAudit(AUDITADDCHILDGROUP, envName, []string{datastore}, nil, []string{parentName, childName})
+	return a.finish(s.addChildGroup(parentName, childName, envName, datastore))
+}
+
+func (s *Store) addChildGroup(parentName, childName, envName, datastore string) error {
+	// groups added before their names were checked may not be nested
+	for _, name := range []string{parentName, childName} {
+		if !ValidInventoryName(name) {
+			return groupError(name, "", ErrInvalidName)
+		}
+	}
+
+	exists, err := s.GroupExists(childName, envName, datastore)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return groupError(childName, datastore, ErrNotFound)
+	}
+
+	cycle, err := s.createsCycle(parentName, childName, envName, datastore)
+	if err != nil {
+		return err
+	}
+	if cycle {
+		return describe("child group", childName+" of group "+parentName, "", ErrCycle)
+	}
+
+	err = s.backend.AddGroupChild(datastore, envName, parentName, childName)
+	return groupError(parentName, datastore, err)
+}
+
+// RemoveChildGroup removes a child group from a parent group
+func (s *Store) RemoveChildGroup(parentName, childName, envName, datastore string) error {
+	a := s.startAudit(AUDITREMOVECHILDGROUP, envName, []string{datastore}, nil, []string{parentName, childName})
+	return a.finish(s.removeChildGroup(parentName, childName, envName, datastore))
+}
+
+func (s *Store) removeChildGroup(parentName, childName, envName, datastore string) error {
+	err := s.backend.RemoveGroupChild(datastore, envName, parentName, childName)
+	return groupError(parentName, datastore, err)
+}
+
+// reports whether nesting childName under parentName would make a group its own ancestor
+func (s *Store) createsCycle(parentName, childName, envName, datastore string) (bool, error) {
+	groups, err := s.Groups(envName, datastore)
+	if err != nil {
+		return false, err
+	}
+
+	// build the parent -> children graph of the environment
+	childMap := make(map[string][]string)
+	for _, ansibleGrp := range groups {
+		childMap[ansibleGrp.Name] = ansibleGrp.Children
+	}
+
+	// walk down from the child, reaching the parent means the new edge closes a loop
+	visited := make(map[string]bool)
+	pending := []string{childName}
+	for len(pending) > 0 {
+		current := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+
+		if current == parentName {
+			return true, nil
+		}
+		if visited[current] {
+			continue
+		}
+		visited[current] = true
+		pending = append(pending, childMap[current]...)
+	}
+
+	return false, nil
+}
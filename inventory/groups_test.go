@@ -0,0 +1,184 @@
+package inventory
+
+import (
+	"testing"
+)
+
+func TestDeleteGroup(t *testing.T) {
+	forEachBackend(t, testDeleteGroup)
+}
+
+func testDeleteGroup(t *testing.T, open openBackend) {
+	s := newTestStoreOn(t, open(t))
+	mustAddHost(t, s, "web01.example.com", PROVISIONER)
+	mustAddHost(t, s, "web02.example.com", PROVISIONER)
+	mustAddGroup(t, s, "web", PROVISIONER)
+	mustAddGroup(t, s, "frontend", PROVISIONER)
+	mustAddGroup(t, s, "edge", PROVISIONER)
+	mustAttach(t, s, "web01.example.com", "web", PROVISIONER)
+	mustAttach(t, s, "web02.example.com", "web", PROVISIONER)
+	checkErr(t, s.AddChildGroup("frontend", "web", testEnv, PROVISIONER), nil)
+	checkErr(t, s.AddChildGroup("edge", "web", testEnv, PROVISIONER), nil)
+	checkErr(t, s.AddChildGroup("edge", "frontend", testEnv, PROVISIONER), nil)
+
+	checkErr(t, s.DeleteGroup("web", testEnv, PROVISIONER), nil)
+
+	exists, err := s.GroupExists("web", testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+	if exists {
+		t.Error("deleted group still exists")
+	}
+
+	// the hosts stay but lose the group
+	for _, host := range []string{"web01.example.com", "web02.example.com"} {
+		checkStrings(t, host+" groups", hostGroups(t, s, host, PROVISIONER), []string{AllGroup(testEnv)})
+	}
+
+	// the parents lose the child, other children stay
+	frontend, err := s.Group("frontend", testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+	checkStrings(t, "frontend children", frontend.Children, nil)
+	edge, err := s.Group("edge", testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+	checkStrings(t, "edge children", edge.Children, []string{"frontend"})
+
+	// the environment no longer lists the group
+	inv, err := s.Inventory(testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+	if _, ok := inv.Groups["web"]; ok {
+		t.Error("inventory still lists the deleted group")
+	}
+	env, err := s.backend.Environment(PROVISIONER, testEnv)
+	checkErr(t, err, nil)
+	if env.Groups["web"] {
+		t.Error("environment still lists the deleted group")
+	}
+
+	// deleting it again reports the group missing
+	checkErr(t, s.DeleteGroup("web", testEnv, PROVISIONER), ErrNotFound)
+}
+
+func TestDeleteDefaultGroup(t *testing.T) {
+	forEachBackend(t, testDeleteDefaultGroup)
+}
+
+func testDeleteDefaultGroup(t *testing.T, open openBackend) {
+	s := newTestStoreOn(t, open(t))
+
+	err := s.DeleteGroup(AllGroup(testEnv), testEnv, PROVISIONER)
+	checkErr(t, err, ErrInUse)
+
+	exists, err := s.GroupExists(AllGroup(testEnv), testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+	if !exists {
+		t.Error("the default group was deleted while its environment exists")
+	}
+}
+
+func TestAddGroup(t *testing.T) {
+	forEachBackend(t, testAddGroup)
+}
+
+func testAddGroup(t *testing.T, open openBackend) {
+	tests := []struct {
+		name    string
+		group   AnsibleGroups
+		wantErr error
+	}{
+		{name: "new group", group: AnsibleGroups{Name: "web", Environment: testEnv}},
+		{name: "duplicate group", group: AnsibleGroups{Name: "db", Environment: testEnv}, wantErr: ErrAlreadyExists},
+		{name: "invalid name", group: AnsibleGroups{Name: "web.servers", Environment: testEnv}, wantErr: ErrInvalidName},
+		{name: "section name", group: AnsibleGroups{Name: "a:children", Environment: testEnv}, wantErr: ErrInvalidName},
+		{name: "brackets", group: AnsibleGroups{Name: "web[1]", Environment: testEnv}, wantErr: ErrInvalidName},
+		{name: "space", group: AnsibleGroups{Name: "a b", Environment: testEnv}, wantErr: ErrInvalidName},
+		{name: "missing environment", group: AnsibleGroups{Name: "web", Environment: "nowhere"}, wantErr: ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStoreOn(t, open(t))
+			mustAddGroup(t, s, "db", PROVISIONER)
+
+			err := s.AddGroup(tt.group, PROVISIONER)
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+
+			inv, err := s.Inventory(testEnv, PROVISIONER)
+			checkErr(t, err, nil)
+			if _, ok := inv.Groups[tt.group.Name]; !ok {
+				t.Errorf("inventory does not list group %s", tt.group.Name)
+			}
+		})
+	}
+}
+
+func TestAddChildGroup(t *testing.T) {
+	forEachBackend(t, testAddChildGroup)
+}
+
+func testAddChildGroup(t *testing.T, open openBackend) {
+	tests := []struct {
+		name    string
+		nest    [][2]string
+		wantErr error
+	}{
+		{name: "child", nest: [][2]string{{"a", "b"}}},
+		{name: "twice", nest: [][2]string{{"a", "b"}, {"a", "b"}}},
+		{name: "chain", nest: [][2]string{{"a", "b"}, {"b", "c"}}},
+		{name: "self", nest: [][2]string{{"a", "a"}}, wantErr: ErrCycle},
+		{name: "loop", nest: [][2]string{{"a", "b"}, {"b", "c"}, {"c", "a"}}, wantErr: ErrCycle},
+		{name: "missing child", nest: [][2]string{{"a", "z"}}, wantErr: ErrNotFound},
+		{name: "missing parent", nest: [][2]string{{"z", "a"}}, wantErr: ErrNotFound},
+		{name: "invalid child", nest: [][2]string{{"a", "a:children"}}, wantErr: ErrInvalidName},
+		{name: "invalid parent", nest: [][2]string{{"web[1]", "a"}}, wantErr: ErrInvalidName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStoreOn(t, open(t))
+			for _, name := range []string{"a", "b", "c"} {
+				mustAddGroup(t, s, name, PROVISIONER)
+			}
+			// groups with names that were accepted by earlier releases
+			for _, name := range []string{"a:children", "web[1]"} {
+				checkErr(t, s.backend.InsertGroup(PROVISIONER, AnsibleGroups{Name: name, Environment: testEnv}), nil)
+			}
+
+			var err error
+			for _, edge := range tt.nest {
+				if err = s.AddChildGroup(edge[0], edge[1], testEnv, PROVISIONER); err != nil {
+					break
+				}
+			}
+			checkErr(t, err, tt.wantErr)
+
+			parent, err := s.Group("a", testEnv, PROVISIONER)
+			checkErr(t, err, nil)
+			if tt.wantErr == nil && len(parent.Children) != 1 {
+				t.Errorf("children of a = %v, want exactly one", parent.Children)
+			}
+		})
+	}
+}
+
+func TestGroupVars(t *testing.T) {
+	forEachBackend(t, testGroupVars)
+}
+
+func testGroupVars(t *testing.T, open openBackend) {
+	s := newTestStoreOn(t, open(t))
+	mustAddGroup(t, s, "web", PROVISIONER)
+
+	checkErr(t, s.SetGroupVars("web", testEnv, PROVISIONER, map[string]string{"http_port": "80", "tier": "front"}), nil)
+	checkErr(t, s.UnsetGroupVars("web", testEnv, PROVISIONER, []string{"tier"}), nil)
+	checkErr(t, s.SetGroupVars("web", testEnv, PROVISIONER, map[string]string{"$set": "x"}), ErrInvalidName)
+	checkErr(t, s.SetGroupVars("nosuchgroup", testEnv, PROVISIONER, map[string]string{"tier": "x"}), ErrNotFound)
+
+	group, err := s.Group("web", testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+	if len(group.Vars) != 1 || group.Vars["http_port"] != "80" {
+		t.Errorf("vars = %v, want only http_port=80", group.Vars)
+	}
+}
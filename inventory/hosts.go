@@ -0,0 +1,209 @@
+package inventory
+
+import (
+	"fmt"
+	"sort"
+)
+
+// HostExists reports whether a host exists in an environment of a datastore
+func (s *Store) HostExists(hostName, envName, datastore string) (bool, error) {
+	_, err := s.backend.Host(datastore, envName, hostName)
+	if err == ErrNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// Host returns a host of an environment
+func (s *Store) Host(hostName, envName, datastore string) (AnsibleHost, error) {
+	result, err := s.backend.Host(datastore, envName, hostName)
+	return result, hostError(hostName, datastore, err)
+}
+
+// Hosts returns the hosts of an environment that match the filter, sorted by name
+func (s *Store) Hosts(envName, datastore string, filter HostFilter) ([]AnsibleHost, error) {
+	result, err := s.backend.Hosts(datastore, envName, filter)
+	sort.Slice(result, func(i, j int) bool { return result[i].Fqdn < result[j].Fqdn })
+	return result, err
+}
+
+// HostVars returns the variables handed to ansible for a host
+func (s *Store) HostVars(hostName, envName, datastore string) (map[string]string, error) {
+	result, err := s.Host(hostName, envName, datastore)
+	if err != nil {
+		return nil, err
+	}
+
+	return HostVarMap(result), nil
+}
+
+// AddHost adds a host to its environment and attaches it to the default group of the environment
+func (s *Store) AddHost(newHost AnsibleHost, datastore string) error {
+	a := s.startAudit(AUDITADDHOST, newHost.Environment, []string{datastore}, []string{newHost.Fqdn}, []string{AllGroup(newHost.Environment)})
+	return a.finish(s.addHost(newHost, datastore))
+}
+
+func (s *Store) addHost(newHost AnsibleHost, datastore string) error {
+	exists, err := s.EnvExists(newHost.Environment, datastore)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return envError(newHost.Environment, datastore, ErrNotFound)
+	}
+
+	if newHost.Groups == nil {
+		newHost.Groups = make(map[string]bool)
+	}
+	if newHost.Vars == nil {
+		newHost.Vars = make(map[string]string)
+	}
+
+	err = s.backend.InsertHost(datastore, newHost)
+	if err != nil {
+		return hostError(newHost.Fqdn, datastore, err)
+	}
+
+	return s.attachHost(newHost.Fqdn, AllGroup(newHost.Environment), newHost.Environment, datastore)
+}
+
+// CloneHost adds a host with the facts, variables and groups of a template host
+func (s *Store) CloneHost(templateName, hostName, envName, datastore string) error {
+	a := s.startAudit(AUDITCLONEHOST, envName, []string{datastore}, []string{hostName}, nil)
+	return a.finish(s.cloneHost(templateName, hostName, envName, datastore))
+}
+
+func (s *Store) cloneHost(templateName, hostName, envName, datastore string) error {
+	template, err := s.Host(templateName, envName, datastore)
+	if err != nil {
+		return err
+	}
+
+	// copy template host field values to new host except Fqdn field value
+	newHost := template
+	newHost.Fqdn = hostName
+
+	err = s.backend.InsertHost(datastore, newHost)
+	if err != nil {
+		return hostError(hostName, datastore, err)
+	}
+
+	for group := range newHost.Groups {
+		err = s.backend.AddGroupMember(datastore, envName, group, hostName)
+		if err != nil {
+			return fmt.Errorf("attaching host %s to group %s: %w", hostName, group, groupError(group, datastore, err))
+		}
+	}
+
+	return nil
+}
+
+// AttachHost adds a host to a group, attaching a host twice is harmless
+func (s *Store) AttachHost(hostName, groupName, envName, datastore string) error {
+	a := s.startAudit(AUDITATTACHHOST, envName, []string{datastore}, []string{hostName}, []string{groupName})
+	return a.finish(s.attachHost(hostName, groupName, envName, datastore))
+}
+
+func (s *Store) attachHost(hostName, groupName, envName, datastore string) error {
+	exists, err := s.HostExists(hostName, envName, datastore)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return hostError(hostName, datastore, ErrNotFound)
+	}
+
+	err = s.backend.AddGroupMember(datastore, envName, groupName, hostName)
+	if err != nil {
+		return groupError(groupName, datastore, err)
+	}
+
+	err = s.backend.SetHostGroup(datastore, envName, hostName, groupName)
+	return hostError(hostName, datastore, err)
+}
+
+// DetachHost removes a host from a group, other than the default group of its environment
+func (s *Store) DetachHost(hostName, groupName, envName, datastore string) error {
+	a := s.startAudit(AUDITDETACHHOST, envName, []string{datastore}, []string{hostName}, []string{groupName})
+	return a.finish(s.detachHost(hostName, groupName, envName, datastore))
+}
+
+func (s *Store) detachHost(hostName, groupName, envName, datastore string) error {
+	// every host stays in the default group, it is listed by it in the inventory file
+	if groupName == AllGroup(envName) {
+		return describe("group to detach from", groupName, datastore, ErrInvalidName)
+	}
+
+	err := s.backend.RemoveGroupMember(datastore, envName, groupName, hostName)
+	if err != nil {
+		return groupError(groupName, datastore, err)
+	}
+
+	err = s.backend.UnsetHostGroup(datastore, envName, hostName, groupName)
+	return hostError(hostName, datastore, err)
+}
+
+// DeleteHost removes a host from its groups and from its environment
+func (s *Store) DeleteHost(hostName, envName, datastore string) error {
+	a := s.startAudit(AUDITDELETEHOST, envName, []string{datastore}, []string{hostName}, nil)
+	return a.finish(s.deleteHost(hostName, envName, datastore))
+}
+
+func (s *Store) deleteHost(hostName, envName, datastore string) error {
+	result, err := s.Host(hostName, envName, datastore)
+	if err != nil {
+		return err
+	}
+
+	for group := range result.Groups {
+		err = s.backend.RemoveGroupMember(datastore, envName, group, hostName)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+	}
+
+	err = s.backend.DeleteHost(datastore, envName, hostName)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	return nil
+}
+
+// SetHostVars sets variables on a host, variables that are not supplied are left untouched
+func (s *Store) SetHostVars(hostName, envName, datastore string, varMap map[string]string) error {
+	a := s.startAudit(AUDITSETHOSTVARS, envName, []string{datastore}, []string{hostName}, nil)
+	return a.finish(s.setHostVars(hostName, envName, datastore, varMap))
+}
+
+func (s *Store) setHostVars(hostName, envName, datastore string, varMap map[string]string) error {
+	if err := checkVarNames(varNames(varMap)); err != nil {
+		return err
+	}
+	if len(varMap) == 0 {
+		return nil
+	}
+
+	err := s.backend.SetHostVars(datastore, envName, hostName, varMap)
+	return hostError(hostName, datastore, err)
+}
+
+// UnsetHostVars removes variables from a host
+func (s *Store) UnsetHostVars(hostName, envName, datastore string, keys []string) error {
+	a := s.startAudit(AUDITUNSETHOSTVARS, envName, []string{datastore}, []string{hostName}, nil)
+	return a.finish(s.unsetHostVars(hostName, envName, datastore, keys))
+}
+
+func (s *Store) unsetHostVars(hostName, envName, datastore string, keys []string) error {
+	names := trimVarNames(keys)
+	if err := checkVarNames(names); err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	err := s.backend.UnsetHostVars(datastore, envName, hostName, names)
+	return hostError(hostName, datastore, err)
+}
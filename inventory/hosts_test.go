@@ -0,0 +1,327 @@
+package inventory
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAddHost(t *testing.T) {
+	forEachBackend(t, testAddHost)
+}
+
+func testAddHost(t *testing.T, open openBackend) {
+	allGroup := AllGroup(testEnv)
+
+	tests := []struct {
+		name    string
+		host    AnsibleHost
+		setup   func(t *testing.T, s *Store)
+		wantErr error
+	}{
+		{
+			name: "new host",
+			host: AnsibleHost{Fqdn: "web01.example.com", Environment: testEnv},
+		},
+		{
+			name: "keeps facts and variables",
+			host: AnsibleHost{Fqdn: "web01.example.com", Environment: testEnv, OsType: "linux", Vars: map[string]string{"role": "web"}},
+		},
+		{
+			name:    "duplicate host",
+			host:    AnsibleHost{Fqdn: "web01.example.com", Environment: testEnv},
+			setup:   func(t *testing.T, s *Store) { mustAddHost(t, s, "web01.example.com", PROVISIONER) },
+			wantErr: ErrAlreadyExists,
+		},
+		{
+			name:    "missing environment",
+			host:    AnsibleHost{Fqdn: "web01.example.com", Environment: "nowhere"},
+			wantErr: ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStoreOn(t, open(t))
+			if tt.setup != nil {
+				tt.setup(t, s)
+			}
+
+			err := s.AddHost(tt.host, PROVISIONER)
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+
+			checkStrings(t, "host groups", hostGroups(t, s, tt.host.Fqdn, PROVISIONER), []string{allGroup})
+			checkStrings(t, "default group members", members(t, s, allGroup, PROVISIONER), []string{tt.host.Fqdn})
+
+			vars, err := s.HostVars(tt.host.Fqdn, testEnv, PROVISIONER)
+			checkErr(t, err, nil)
+			for k, v := range tt.host.Vars {
+				if vars[k] != v {
+					t.Errorf("variable %s = %q, want %q", k, vars[k], v)
+				}
+			}
+
+			// the host must not show up in the other datastore
+			exists, err := s.HostExists(tt.host.Fqdn, testEnv, CUSTODIAN)
+			checkErr(t, err, nil)
+			if exists {
+				t.Errorf("host %s exists in the custodian datastore", tt.host.Fqdn)
+			}
+		})
+	}
+}
+
+func TestCloneHost(t *testing.T) {
+	forEachBackend(t, testCloneHost)
+}
+
+func testCloneHost(t *testing.T, open openBackend) {
+	allGroup := AllGroup(testEnv)
+
+	tests := []struct {
+		name       string
+		template   string
+		clone      string
+		wantErr    error
+		wantGroups []string
+	}{
+		{name: "clone", template: "web01.example.com", clone: "web02.example.com", wantGroups: []string{allGroup, "web"}},
+		{name: "missing template", template: "db01.example.com", clone: "web02.example.com", wantErr: ErrNotFound},
+		{name: "clone exists", template: "web01.example.com", clone: "web01.example.com", wantErr: ErrAlreadyExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStoreOn(t, open(t))
+			mustAddHost(t, s, "web01.example.com", PROVISIONER)
+			mustAddGroup(t, s, "web", PROVISIONER)
+			mustAttach(t, s, "web01.example.com", "web", PROVISIONER)
+			if err := s.SetHostVars("web01.example.com", testEnv, PROVISIONER, map[string]string{"role": "web"}); err != nil {
+				t.Fatal(err)
+			}
+
+			err := s.CloneHost(tt.template, tt.clone, testEnv, PROVISIONER)
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+
+			clone, err := s.Host(tt.clone, testEnv, PROVISIONER)
+			checkErr(t, err, nil)
+			if clone.OsType != "linux" || clone.Vars["role"] != "web" {
+				t.Errorf("clone did not copy the template facts and variables: %+v", clone)
+			}
+			checkStrings(t, "clone groups", hostGroups(t, s, tt.clone, PROVISIONER), tt.wantGroups)
+			for _, group := range tt.wantGroups {
+				checkStrings(t, group+" members", members(t, s, group, PROVISIONER), []string{tt.template, tt.clone})
+			}
+
+			// changing the clone must leave the template alone
+			checkErr(t, s.SetHostVars(tt.clone, testEnv, PROVISIONER, map[string]string{"role": "db"}), nil)
+			template, err := s.Host(tt.template, testEnv, PROVISIONER)
+			checkErr(t, err, nil)
+			if template.Vars["role"] != "web" {
+				t.Errorf("template variable role = %q after changing the clone", template.Vars["role"])
+			}
+		})
+	}
+}
+
+func TestAttachDetachHost(t *testing.T) {
+	forEachBackend(t, testAttachDetachHost)
+}
+
+func testAttachDetachHost(t *testing.T, open openBackend) {
+	allGroup := AllGroup(testEnv)
+
+	tests := []struct {
+		name        string
+		attach      []string
+		detach      []string
+		wantErr     error
+		wantGroups  []string
+		wantMembers map[string][]string
+	}{
+		{
+			name:        "attach",
+			attach:      []string{"web"},
+			wantGroups:  []string{allGroup, "web"},
+			wantMembers: map[string][]string{"web": {"web01.example.com"}},
+		},
+		{
+			name:        "attach twice",
+			attach:      []string{"web", "web"},
+			wantGroups:  []string{allGroup, "web"},
+			wantMembers: map[string][]string{"web": {"web01.example.com"}},
+		},
+		{
+			name:        "attach and detach",
+			attach:      []string{"web", "db"},
+			detach:      []string{"web"},
+			wantGroups:  []string{allGroup, "db"},
+			wantMembers: map[string][]string{"web": nil, "db": {"web01.example.com"}},
+		},
+		{
+			name:        "detach a group the host is not in",
+			detach:      []string{"web"},
+			wantGroups:  []string{allGroup},
+			wantMembers: map[string][]string{"web": nil},
+		},
+		{
+			name:    "attach to a missing group",
+			attach:  []string{"nosuchgroup"},
+			wantErr: ErrNotFound,
+		},
+		{
+			name:    "detach from the default group",
+			detach:  []string{allGroup},
+			wantErr: ErrInvalidName,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStoreOn(t, open(t))
+			mustAddHost(t, s, "web01.example.com", PROVISIONER)
+			mustAddGroup(t, s, "web", PROVISIONER)
+			mustAddGroup(t, s, "db", PROVISIONER)
+
+			var err error
+			for _, group := range tt.attach {
+				if err = s.AttachHost("web01.example.com", group, testEnv, PROVISIONER); err != nil {
+					break
+				}
+			}
+			for _, group := range tt.detach {
+				if err != nil {
+					break
+				}
+				err = s.DetachHost("web01.example.com", group, testEnv, PROVISIONER)
+			}
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				// a failed attach must not leave the host half attached
+				checkStrings(t, "host groups", hostGroups(t, s, "web01.example.com", PROVISIONER), []string{allGroup})
+				return
+			}
+
+			checkStrings(t, "host groups", hostGroups(t, s, "web01.example.com", PROVISIONER), tt.wantGroups)
+			for group, want := range tt.wantMembers {
+				checkStrings(t, group+" members", members(t, s, group, PROVISIONER), want)
+			}
+		})
+	}
+}
+
+func TestAttachMissingHost(t *testing.T) {
+	forEachBackend(t, testAttachMissingHost)
+}
+
+func testAttachMissingHost(t *testing.T, open openBackend) {
+	s := newTestStoreOn(t, open(t))
+	mustAddGroup(t, s, "web", PROVISIONER)
+
+	err := s.AttachHost("ghost.example.com", "web", testEnv, PROVISIONER)
+	checkErr(t, err, ErrNotFound)
+	checkStrings(t, "web members", members(t, s, "web", PROVISIONER), nil)
+}
+
+func TestDeleteHost(t *testing.T) {
+	forEachBackend(t, testDeleteHost)
+}
+
+func testDeleteHost(t *testing.T, open openBackend) {
+	s := newTestStoreOn(t, open(t))
+	mustAddHost(t, s, "web01.example.com", PROVISIONER)
+	mustAddHost(t, s, "web02.example.com", PROVISIONER)
+	mustAddGroup(t, s, "web", PROVISIONER)
+	mustAttach(t, s, "web01.example.com", "web", PROVISIONER)
+	mustAttach(t, s, "web02.example.com", "web", PROVISIONER)
+
+	checkErr(t, s.DeleteHost("web01.example.com", testEnv, PROVISIONER), nil)
+
+	exists, err := s.HostExists("web01.example.com", testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+	if exists {
+		t.Error("deleted host still exists")
+	}
+	checkStrings(t, "web members", members(t, s, "web", PROVISIONER), []string{"web02.example.com"})
+	checkStrings(t, "default group members", members(t, s, AllGroup(testEnv), PROVISIONER), []string{"web02.example.com"})
+
+	checkErr(t, s.DeleteHost("web01.example.com", testEnv, PROVISIONER), ErrNotFound)
+}
+
+func TestHostVars(t *testing.T) {
+	forEachBackend(t, testHostVars)
+}
+
+func testHostVars(t *testing.T, open openBackend) {
+	tests := []struct {
+		name    string
+		set     map[string]string
+		unset   []string
+		wantErr error
+		want    map[string]string
+	}{
+		{name: "set", set: map[string]string{"role": "web", "port": "8080"}, want: map[string]string{"role": "web", "port": "8080"}},
+		{name: "set and unset", set: map[string]string{"role": "web", "port": "8080"}, unset: []string{" port "}, want: map[string]string{"role": "web"}},
+		{name: "invalid name", set: map[string]string{"bad.name": "x"}, wantErr: ErrInvalidName},
+		{name: "unset invalid name", unset: []string{"$where"}, wantErr: ErrInvalidName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStoreOn(t, open(t))
+			mustAddHost(t, s, "web01.example.com", PROVISIONER)
+
+			err := s.SetHostVars("web01.example.com", testEnv, PROVISIONER, tt.set)
+			if err == nil {
+				err = s.UnsetHostVars("web01.example.com", testEnv, PROVISIONER, tt.unset)
+			}
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				return
+			}
+
+			host, err := s.Host("web01.example.com", testEnv, PROVISIONER)
+			checkErr(t, err, nil)
+			if len(host.Vars) != len(tt.want) {
+				t.Errorf("vars = %v, want %v", host.Vars, tt.want)
+			}
+			for k, v := range tt.want {
+				if host.Vars[k] != v {
+					t.Errorf("variable %s = %q, want %q", k, host.Vars[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestParseVars(t *testing.T) {
+	tests := []struct {
+		name    string
+		vars    string
+		wantErr error
+		want    map[string]string
+	}{
+		{name: "pairs", vars: "role=web, port = 8080", want: map[string]string{"role": "web", "port": "8080"}},
+		{name: "value with equals", vars: "opts=a=b", want: map[string]string{"opts": "a=b"}},
+		{name: "trailing comma", vars: "role=web,", want: map[string]string{"role": "web"}},
+		{name: "doubled comma", vars: "role=web,, port=8080", want: map[string]string{"role": "web", "port": "8080"}},
+		{name: "no pairs", vars: " , ", wantErr: ErrMalformed},
+		{name: "missing value", vars: "role", wantErr: ErrMalformed},
+		{name: "invalid name", vars: "1role=web", wantErr: ErrInvalidName},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseVars(tt.vars)
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseVars(%q) = %v, want %v", tt.vars, got, tt.want)
+			}
+		})
+	}
+}
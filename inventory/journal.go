@@ -0,0 +1,271 @@
+package inventory
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Journal collection, every push and pull is recorded in the provisioner datastore
+// before it touches either datastore so an interrupted move can be resumed.
+const JOURNALCOLLECTION string = "journal"
+
+// Move states, a move is rolled back while copying and rolled forward once removing
+const MOVECOPYING string = "copying"
+const MOVEREMOVING string = "removing"
+const MOVEDONE string = "done"
+const MOVEROLLEDBACK string = "rolledback"
+
+// Type Definitions
+
+// MoveOperation is the journal entry of a host moving from one datastore to the other
+type MoveOperation struct {
+	Id            string `bson:"_id" json:"id"`
+	Host          string
+	Environment   string
+	From          string
+	To            string
+	State         string
+	Snapshot      AnsibleHost
+	CreatedGroups []string
+	Started       time.Time
+	Updated       time.Time
+}
+
+// MoveHost moves a host and its group memberships from one datastore to the other.
+// The move is journaled first, a failure while copying rolls the move back and a failure
+// after the copy is complete is left to ResumeMoves to finish.
+func (s *Store) MoveHost(host, envName, from, to string) error {
+	a := s.startAudit(AUDITMOVEHOST, envName, []string{from, to}, []string{host}, nil)
+	return a.finish(s.moveHost(host, envName, from, to))
+}
+
+func (s *Store) moveHost(host, envName, from, to string) error {
+	// refuse to start a second move of the same host while one is unfinished
+	pending, err := s.backend.UnfinishedMoves()
+	if err != nil {
+		return err
+	}
+	for _, op := range pending {
+		if op.Host == host && op.Environment == envName {
+			return moveError(host, ErrUnfinished)
+		}
+	}
+
+	snapshot, err := s.Host(host, envName, from)
+	if err != nil {
+		return err
+	}
+
+	exists, err := s.HostExists(host, envName, to)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return hostError(host, to, ErrAlreadyExists)
+	}
+
+	id, err := newId()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	op := &MoveOperation{
+		Id:          id,
+		Host:        host,
+		Environment: envName,
+		From:        from,
+		To:          to,
+		State:       MOVECOPYING,
+		Snapshot:    snapshot,
+		Started:     now,
+		Updated:     now,
+	}
+	if err = s.backend.InsertMove(*op); err != nil {
+		return err
+	}
+
+	if err = s.copyMove(op); err != nil {
+		if rbErr := s.rollbackMove(op); rbErr != nil {
+			return fmt.Errorf("%v, rolling back failed: %v: %w", err, rbErr, moveError(host, ErrUnfinished))
+		}
+		return err
+	}
+
+	if err = s.finishMove(op); err != nil {
+		return fmt.Errorf("%v, the host is copied but not removed: %w", err, moveError(host, ErrUnfinished))
+	}
+
+	return nil
+}
+
+// ResumeMoves finishes or rolls back every move left unfinished by an interrupted push or pull
+// and returns the moves it resumed
+func (s *Store) ResumeMoves() ([]MoveOperation, error) {
+	ops, err := s.backend.UnfinishedMoves()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range ops {
+		op := &ops[i]
+		a := s.startAudit(AUDITRESUMEMOVE, op.Environment, []string{op.From, op.To}, []string{op.Host}, nil)
+		switch op.State {
+		case MOVECOPYING:
+			err = a.finish(s.rollbackMove(op))
+		case MOVEREMOVING:
+			err = a.finish(s.finishMove(op))
+		}
+		if err != nil {
+			return ops[:i], fmt.Errorf("resuming move %s of host %s: %w", op.Id, op.Host, err)
+		}
+	}
+
+	return ops, nil
+}
+
+// copies the host into the target datastore and adds it to its groups there,
+// creating the groups that are missing. Every step may safely be repeated.
+func (s *Store) copyMove(op *MoveOperation) error {
+	err := s.backend.PutHost(op.To, op.Snapshot)
+	if err != nil {
+		return err
+	}
+
+	for group := range op.Snapshot.Groups {
+		exists, err := s.GroupExists(group, op.Environment, op.To)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			sourceGroup, err := s.backend.Group(op.From, op.Environment, group)
+			if err != nil {
+				return fmt.Errorf("group %s is referenced by host %s but missing from the %s database", group, op.Host, op.From)
+			}
+
+			// record the group before creating it so a rollback removes it again
+			op.CreatedGroups = addToSet(op.CreatedGroups, group)
+			if err = s.updateMove(op); err != nil {
+				return err
+			}
+
+			newGroup := sourceGroup
+			newGroup.Members = map[string][]string{group: make([]string, 0)}
+			err = s.backend.InsertGroup(op.To, newGroup)
+			if err == ErrAlreadyExists {
+				// a concurrent run created the group in the meantime, it is not the move's to remove
+				op.CreatedGroups = pull(op.CreatedGroups, group)
+				err = s.updateMove(op)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		err = s.backend.SetEnvironmentGroup(op.To, op.Environment, group)
+		if err != nil {
+			return fmt.Errorf("updating environment %s in the %s database: %w", op.Environment, op.To, err)
+		}
+
+		err = s.backend.AddGroupMember(op.To, op.Environment, group, op.Host)
+		if err != nil {
+			return fmt.Errorf("adding host %s to group %s in the %s database: %w", op.Host, group, op.To, err)
+		}
+	}
+
+	return s.setMoveState(op, MOVEREMOVING)
+}
+
+// removes the host from its groups and from the source datastore, the copy is complete at this point
+func (s *Store) finishMove(op *MoveOperation) error {
+	for group := range op.Snapshot.Groups {
+		err := s.backend.RemoveGroupMember(op.From, op.Environment, group, op.Host)
+		if err != nil && err != ErrNotFound {
+			return fmt.Errorf("removing host %s from group %s in the %s database: %w", op.Host, group, op.From, err)
+		}
+	}
+
+	err := s.backend.DeleteHost(op.From, op.Environment, op.Host)
+	if err != nil && err != ErrNotFound {
+		return fmt.Errorf("removing host %s from the %s database: %w", op.Host, op.From, err)
+	}
+
+	return s.setMoveState(op, MOVEDONE)
+}
+
+// undoes the copy of an unfinished move, the source datastore has not been touched yet
+func (s *Store) rollbackMove(op *MoveOperation) error {
+	for group := range op.Snapshot.Groups {
+		err := s.backend.RemoveGroupMember(op.To, op.Environment, group, op.Host)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+	}
+
+	// only remove the groups the move created, and only while nothing else joined them
+	for _, group := range op.CreatedGroups {
+		createdGroup, err := s.backend.Group(op.To, op.Environment, group)
+		if err == ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if len(createdGroup.Members[group]) > 0 {
+			continue
+		}
+
+		err = s.backend.DeleteGroup(op.To, op.Environment, group)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+
+		err = s.backend.UnsetEnvironmentGroup(op.To, op.Environment, group)
+		if err != nil && err != ErrNotFound {
+			return err
+		}
+	}
+
+	err := s.backend.DeleteHost(op.To, op.Environment, op.Host)
+	if err != nil && err != ErrNotFound {
+		return err
+	}
+
+	return s.setMoveState(op, MOVEROLLEDBACK)
+}
+
+func (s *Store) setMoveState(op *MoveOperation, state string) error {
+	previous := op.State
+	op.State = state
+	if err := s.updateMove(op); err != nil {
+		op.State = previous
+		return err
+	}
+	return nil
+}
+
+// writes the journal entry of a move back to the backend
+func (s *Store) updateMove(op *MoveOperation) error {
+	op.Updated = time.Now()
+	err := s.backend.UpdateMove(*op)
+	if err == ErrNotFound {
+		return errors.New("the journal entry of move " + op.Id + " is missing")
+	}
+	return err
+}
+
+// returns a random identifier for a journal entry or audit record
+func newId() (string, error) {
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("generating an id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+func moveError(host string, err error) error {
+	return &Error{Kind: "move of host", Name: host, Err: err}
+}
@@ -0,0 +1,326 @@
+package inventory
+
+import (
+	"errors"
+	"testing"
+)
+
+var errInjected = errors.New("injected failure")
+
+// failingBackend fails adding members to the groups of one datastore, the way a move fails
+// half way through copying a host
+type failingBackend struct {
+	Backend
+	datastore string
+	failing   bool
+}
+
+func (f *failingBackend) AddGroupMember(datastore, envName, groupName, hostName string) error {
+	if f.failing && datastore == f.datastore {
+		return errInjected
+	}
+	return f.Backend.AddGroupMember(datastore, envName, groupName, hostName)
+}
+
+// failingRemoveBackend fails deleting hosts from one datastore, the way a move fails after
+// the copy is complete
+type failingRemoveBackend struct {
+	Backend
+	datastore string
+	failing   bool
+}
+
+func (f *failingRemoveBackend) DeleteHost(datastore, envName, hostName string) error {
+	if f.failing && datastore == f.datastore {
+		return errInjected
+	}
+	return f.Backend.DeleteHost(datastore, envName, hostName)
+}
+
+// racingBackend creates a group in one datastore just before the store inserts it, the way a
+// concurrent run creates it between the check and the insert, and then fails adding members to it
+type racingBackend struct {
+	Backend
+	datastore string
+	group     string
+}
+
+func (r *racingBackend) InsertGroup(datastore string, group AnsibleGroups) error {
+	if datastore == r.datastore && group.Name == r.group {
+		if err := r.Backend.InsertGroup(datastore, AnsibleGroups{Name: group.Name, Environment: group.Environment}); err != nil {
+			return err
+		}
+	}
+	return r.Backend.InsertGroup(datastore, group)
+}
+
+func (r *racingBackend) AddGroupMember(datastore, envName, groupName, hostName string) error {
+	if datastore == r.datastore && groupName == r.group {
+		return errInjected
+	}
+	return r.Backend.AddGroupMember(datastore, envName, groupName, hostName)
+}
+
+// adds web01 to the provisioner datastore in the web and app groups
+func setupMove(t *testing.T, s *Store) {
+	t.Helper()
+	mustAddHost(t, s, "web01.example.com", PROVISIONER)
+	mustAddHost(t, s, "web02.example.com", PROVISIONER)
+	mustAddGroup(t, s, "web", PROVISIONER)
+	mustAddGroup(t, s, "app", PROVISIONER)
+	mustAttach(t, s, "web01.example.com", "web", PROVISIONER)
+	mustAttach(t, s, "web01.example.com", "app", PROVISIONER)
+	mustAttach(t, s, "web02.example.com", "web", PROVISIONER)
+	checkErr(t, s.SetGroupVars("app", testEnv, PROVISIONER, map[string]string{"tier": "app"}), nil)
+	checkErr(t, s.SetHostVars("web01.example.com", testEnv, PROVISIONER, map[string]string{"role": "web"}), nil)
+}
+
+func TestMoveHost(t *testing.T) {
+	forEachBackend(t, testMoveHost)
+}
+
+func testMoveHost(t *testing.T, open openBackend) {
+	allGroup := AllGroup(testEnv)
+
+	tests := []struct {
+		name    string
+		host    string
+		setup   func(t *testing.T, s *Store)
+		wantErr error
+	}{
+		{
+			name: "groups missing in the target",
+			host: "web01.example.com",
+		},
+		{
+			name: "groups present in the target",
+			host: "web01.example.com",
+			setup: func(t *testing.T, s *Store) {
+				mustAddGroup(t, s, "web", CUSTODIAN)
+				mustAddGroup(t, s, "app", CUSTODIAN)
+			},
+		},
+		{
+			name: "host already in the target",
+			host: "web01.example.com",
+			setup: func(t *testing.T, s *Store) {
+				mustAddHost(t, s, "web01.example.com", CUSTODIAN)
+			},
+			wantErr: ErrAlreadyExists,
+		},
+		{
+			name:    "missing host",
+			host:    "ghost.example.com",
+			wantErr: ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStoreOn(t, open(t))
+			setupMove(t, s)
+			if tt.setup != nil {
+				tt.setup(t, s)
+			}
+
+			err := s.MoveHost(tt.host, testEnv, PROVISIONER, CUSTODIAN)
+			checkErr(t, err, tt.wantErr)
+			if tt.wantErr != nil {
+				exists, err := s.HostExists("web01.example.com", testEnv, PROVISIONER)
+				checkErr(t, err, nil)
+				if !exists {
+					t.Error("a refused move removed the host from the source")
+				}
+				return
+			}
+
+			exists, err := s.HostExists(tt.host, testEnv, PROVISIONER)
+			checkErr(t, err, nil)
+			if exists {
+				t.Error("moved host still exists in the source")
+			}
+
+			moved, err := s.Host(tt.host, testEnv, CUSTODIAN)
+			checkErr(t, err, nil)
+			if moved.Vars["role"] != "web" || moved.OsType != "linux" {
+				t.Errorf("moved host lost its facts or variables: %+v", moved)
+			}
+			checkStrings(t, "target groups", hostGroups(t, s, tt.host, CUSTODIAN), []string{allGroup, "app", "web"})
+			for _, group := range []string{allGroup, "app", "web"} {
+				checkStrings(t, "target "+group+" members", members(t, s, group, CUSTODIAN), []string{tt.host})
+			}
+
+			// the source keeps its groups and the other hosts
+			checkStrings(t, "source web members", members(t, s, "web", PROVISIONER), []string{"web02.example.com"})
+			checkStrings(t, "source app members", members(t, s, "app", PROVISIONER), nil)
+
+			// groups created in the target carry the variables of the source group
+			app, err := s.Group("app", testEnv, CUSTODIAN)
+			checkErr(t, err, nil)
+			if tt.setup == nil && app.Vars["tier"] != "app" {
+				t.Errorf("created group vars = %v, want tier=app", app.Vars)
+			}
+
+			inv, err := s.Inventory(testEnv, CUSTODIAN)
+			checkErr(t, err, nil)
+			if _, ok := inv.Groups["app"]; !ok {
+				t.Error("target inventory does not list the app group")
+			}
+
+			ops, err := s.backend.UnfinishedMoves()
+			checkErr(t, err, nil)
+			if len(ops) != 0 {
+				t.Errorf("%d moves left unfinished", len(ops))
+			}
+		})
+	}
+}
+
+func TestMoveHostRoundTrip(t *testing.T) {
+	forEachBackend(t, testMoveHostRoundTrip)
+}
+
+func testMoveHostRoundTrip(t *testing.T, open openBackend) {
+	s := newTestStoreOn(t, open(t))
+	setupMove(t, s)
+
+	before, err := s.Inventory(testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+
+	checkErr(t, s.MoveHost("web01.example.com", testEnv, PROVISIONER, CUSTODIAN), nil)
+	checkErr(t, s.MoveHost("web01.example.com", testEnv, CUSTODIAN, PROVISIONER), nil)
+
+	after, err := s.Inventory(testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+
+	if len(after.Groups) != len(before.Groups) {
+		t.Fatalf("groups after round trip = %d, want %d", len(after.Groups), len(before.Groups))
+	}
+	for name, group := range before.Groups {
+		checkStrings(t, name+" hosts", after.Groups[name].Hosts, group.Hosts)
+	}
+	for host, vars := range before.Meta.HostVars {
+		for k, v := range vars {
+			if after.Meta.HostVars[host][k] != v {
+				t.Errorf("host %s variable %s = %q, want %q", host, k, after.Meta.HostVars[host][k], v)
+			}
+		}
+	}
+
+	// the custodian keeps the groups the push created, but empty
+	for _, group := range []string{"web", "app"} {
+		checkStrings(t, "custodian "+group+" members", members(t, s, group, CUSTODIAN), nil)
+	}
+	exists, err := s.HostExists("web01.example.com", testEnv, CUSTODIAN)
+	checkErr(t, err, nil)
+	if exists {
+		t.Error("host still exists in the custodian after pulling it back")
+	}
+}
+
+func TestMoveHostRollback(t *testing.T) {
+	forEachBackend(t, testMoveHostRollback)
+}
+
+func testMoveHostRollback(t *testing.T, open openBackend) {
+	backend := &failingBackend{Backend: open(t), datastore: CUSTODIAN}
+	s := newTestStoreOn(t, backend)
+	setupMove(t, s)
+	mustAddGroup(t, s, "web", CUSTODIAN)
+
+	backend.failing = true
+	err := s.MoveHost("web01.example.com", testEnv, PROVISIONER, CUSTODIAN)
+	checkErr(t, err, errInjected)
+	if errors.Is(err, ErrUnfinished) {
+		t.Errorf("a rolled back move reported itself unfinished: %v", err)
+	}
+	backend.failing = false
+
+	// the target is as it was, the group the move created is gone again
+	exists, err := s.HostExists("web01.example.com", testEnv, CUSTODIAN)
+	checkErr(t, err, nil)
+	if exists {
+		t.Error("rolled back host still exists in the target")
+	}
+	exists, err = s.GroupExists("app", testEnv, CUSTODIAN)
+	checkErr(t, err, nil)
+	if exists {
+		t.Error("group created by the rolled back move still exists")
+	}
+	exists, err = s.GroupExists("web", testEnv, CUSTODIAN)
+	checkErr(t, err, nil)
+	if !exists {
+		t.Error("rollback removed a group the move did not create")
+	}
+
+	// the source is untouched
+	checkStrings(t, "source groups", hostGroups(t, s, "web01.example.com", PROVISIONER), []string{AllGroup(testEnv), "app", "web"})
+	checkStrings(t, "source web members", members(t, s, "web", PROVISIONER), []string{"web01.example.com", "web02.example.com"})
+
+	// and the host can be moved once the failure is gone
+	checkErr(t, s.MoveHost("web01.example.com", testEnv, PROVISIONER, CUSTODIAN), nil)
+}
+
+func TestResumeMoves(t *testing.T) {
+	forEachBackend(t, testResumeMoves)
+}
+
+func testResumeMoves(t *testing.T, open openBackend) {
+	backend := &failingRemoveBackend{Backend: open(t), datastore: PROVISIONER}
+	s := newTestStoreOn(t, backend)
+	setupMove(t, s)
+
+	backend.failing = true
+	err := s.MoveHost("web01.example.com", testEnv, PROVISIONER, CUSTODIAN)
+	checkErr(t, err, ErrUnfinished)
+
+	// a second move of the same host is refused until the first one is resumed
+	err = s.MoveHost("web01.example.com", testEnv, PROVISIONER, CUSTODIAN)
+	checkErr(t, err, ErrUnfinished)
+
+	_, err = s.ResumeMoves()
+	checkErr(t, err, errInjected)
+
+	backend.failing = false
+	ops, err := s.ResumeMoves()
+	checkErr(t, err, nil)
+	if len(ops) != 1 || ops[0].State != MOVEDONE {
+		t.Fatalf("resumed moves = %+v, want one finished move", ops)
+	}
+
+	exists, err := s.HostExists("web01.example.com", testEnv, PROVISIONER)
+	checkErr(t, err, nil)
+	if exists {
+		t.Error("resumed move left the host in the source")
+	}
+	checkStrings(t, "target web members", members(t, s, "web", CUSTODIAN), []string{"web01.example.com"})
+
+	ops, err = s.ResumeMoves()
+	checkErr(t, err, nil)
+	if len(ops) != 0 {
+		t.Errorf("resuming twice resumed %d moves", len(ops))
+	}
+}
+
+func TestMoveHostConcurrentGroup(t *testing.T) {
+	forEachBackend(t, testMoveHostConcurrentGroup)
+}
+
+func testMoveHostConcurrentGroup(t *testing.T, open openBackend) {
+	s := newTestStoreOn(t, &racingBackend{Backend: open(t), datastore: CUSTODIAN, group: "app"})
+	setupMove(t, s)
+
+	checkErr(t, s.MoveHost("web01.example.com", testEnv, PROVISIONER, CUSTODIAN), errInjected)
+
+	// the rollback leaves the group the concurrent run created
+	exists, err := s.GroupExists("app", testEnv, CUSTODIAN)
+	checkErr(t, err, nil)
+	if !exists {
+		t.Error("rollback removed a group created by a concurrent run")
+	}
+	exists, err = s.HostExists("web01.example.com", testEnv, CUSTODIAN)
+	checkErr(t, err, nil)
+	if exists {
+		t.Error("rolled back host still exists in the target")
+	}
+}
@@ -0,0 +1,414 @@
+package inventory
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+)
+
+// memoryBackend keeps the datastores in memory with the same layout as the bolt backend,
+// records are stored as JSON so callers never share maps or slices with the backend.
+// It is meant for tests and for working on a throwaway copy of an inventory.
+type memoryBackend struct {
+	mu   sync.Mutex
+	data map[string]map[string]map[string][]byte
+}
+
+// NewMemoryBackend returns an empty backend that lives in memory only
+func NewMemoryBackend() Backend {
+	return &memoryBackend{data: make(map[string]map[string]map[string][]byte)}
+}
+
+func (m *memoryBackend) Close() error {
+	return nil
+}
+
+// returns a bucket of a datastore, creating it when create is set. The caller holds the lock.
+func (m *memoryBackend) bucket(datastore, name string, create bool) map[string][]byte {
+	ds, ok := m.data[datastore]
+	if !ok {
+		if !create {
+			return nil
+		}
+		ds = make(map[string]map[string][]byte)
+		m.data[datastore] = ds
+	}
+
+	bk, ok := ds[name]
+	if !ok && create {
+		bk = make(map[string][]byte)
+		ds[name] = bk
+	}
+	return bk
+}
+
+func (m *memoryBackend) get(datastore, name, key string, v interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	data, ok := m.bucket(datastore, name, false)[key]
+	if !ok {
+		return ErrNotFound
+	}
+	return json.Unmarshal(data, v)
+}
+
+func (m *memoryBackend) put(datastore, name, key string, v interface{}, mustExist, mustNotExist bool) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bk := m.bucket(datastore, name, true)
+	_, exists := bk[key]
+	if mustExist && !exists {
+		return ErrNotFound
+	}
+	if mustNotExist && exists {
+		return ErrAlreadyExists
+	}
+	bk[key] = data
+	return nil
+}
+
+func (m *memoryBackend) remove(datastore, name, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bk := m.bucket(datastore, name, false)
+	if _, ok := bk[key]; !ok {
+		return ErrNotFound
+	}
+	delete(bk, key)
+	return nil
+}
+
+// calls fn for every record of a bucket in key order, a record fn changes is written back.
+// The whole walk holds the lock so it is atomic like a bolt transaction.
+func (m *memoryBackend) each(datastore, name string, fn func(data []byte) ([]byte, error)) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bk := m.bucket(datastore, name, false)
+	keys := make([]string, 0, len(bk))
+	for key := range bk {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		updated, err := fn(bk[key])
+		if err != nil {
+			return err
+		}
+		if updated != nil {
+			bk[key] = updated
+		}
+	}
+	return nil
+}
+
+// applies fn to a record in a single step
+func (m *memoryBackend) modify(datastore, name, key string, v interface{}, fn func()) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	bk := m.bucket(datastore, name, false)
+	data, ok := bk[key]
+	if !ok {
+		return ErrNotFound
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return err
+	}
+	fn()
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	bk[key] = data
+	return nil
+}
+
+func (m *memoryBackend) modifyHost(datastore, envName, hostName string, fn func(host *AnsibleHost)) error {
+	host := AnsibleHost{}
+	return m.modify(datastore, hostsBucket(envName), hostName, &host, func() {
+		if host.Groups == nil {
+			host.Groups = make(map[string]bool)
+		}
+		if host.Vars == nil {
+			host.Vars = make(map[string]string)
+		}
+		fn(&host)
+	})
+}
+
+func (m *memoryBackend) modifyGroup(datastore, envName, groupName string, fn func(group *AnsibleGroups)) error {
+	group := AnsibleGroups{}
+	return m.modify(datastore, groupsBucket(envName), groupName, &group, func() {
+		if group.Members == nil {
+			group.Members = make(map[string][]string)
+		}
+		if group.Vars == nil {
+			group.Vars = make(map[string]string)
+		}
+		fn(&group)
+	})
+}
+
+func (m *memoryBackend) modifyEnvironment(datastore, envName string, fn func(env *AnsibleEnvironment)) error {
+	env := AnsibleEnvironment{}
+	return m.modify(datastore, "environments", envName, &env, func() {
+		if env.Groups == nil {
+			env.Groups = make(map[string]bool)
+		}
+		fn(&env)
+	})
+}
+
+func (m *memoryBackend) Host(datastore, envName, hostName string) (AnsibleHost, error) {
+	result := AnsibleHost{}
+	err := m.get(datastore, hostsBucket(envName), hostName, &result)
+	return result, err
+}
+
+func (m *memoryBackend) Hosts(datastore, envName string, filter HostFilter) ([]AnsibleHost, error) {
+	result := []AnsibleHost{}
+	err := m.each(datastore, hostsBucket(envName), func(data []byte) ([]byte, error) {
+		host := AnsibleHost{}
+		if err := json.Unmarshal(data, &host); err != nil {
+			return nil, err
+		}
+		if filter.matches(host) {
+			result = append(result, host)
+		}
+		return nil, nil
+	})
+	return result, err
+}
+
+func (m *memoryBackend) InsertHost(datastore string, host AnsibleHost) error {
+	return m.put(datastore, hostsBucket(host.Environment), host.Fqdn, host, false, true)
+}
+
+func (m *memoryBackend) PutHost(datastore string, host AnsibleHost) error {
+	return m.put(datastore, hostsBucket(host.Environment), host.Fqdn, host, false, false)
+}
+
+func (m *memoryBackend) DeleteHost(datastore, envName, hostName string) error {
+	return m.remove(datastore, hostsBucket(envName), hostName)
+}
+
+func (m *memoryBackend) SetHostGroup(datastore, envName, hostName, groupName string) error {
+	return m.modifyHost(datastore, envName, hostName, func(host *AnsibleHost) {
+		host.Groups[groupName] = true
+	})
+}
+
+func (m *memoryBackend) UnsetHostGroup(datastore, envName, hostName, groupName string) error {
+	return m.modifyHost(datastore, envName, hostName, func(host *AnsibleHost) {
+		delete(host.Groups, groupName)
+	})
+}
+
+func (m *memoryBackend) UnsetGroupFromHosts(datastore, envName, groupName string) error {
+	return m.each(datastore, hostsBucket(envName), func(data []byte) ([]byte, error) {
+		host := AnsibleHost{}
+		if err := json.Unmarshal(data, &host); err != nil {
+			return nil, err
+		}
+		if _, ok := host.Groups[groupName]; !ok {
+			return nil, nil
+		}
+		delete(host.Groups, groupName)
+		return json.Marshal(host)
+	})
+}
+
+func (m *memoryBackend) SetHostVars(datastore, envName, hostName string, varMap map[string]string) error {
+	return m.modifyHost(datastore, envName, hostName, func(host *AnsibleHost) {
+		for k, v := range varMap {
+			host.Vars[k] = v
+		}
+	})
+}
+
+func (m *memoryBackend) UnsetHostVars(datastore, envName, hostName string, keys []string) error {
+	return m.modifyHost(datastore, envName, hostName, func(host *AnsibleHost) {
+		for _, k := range keys {
+			delete(host.Vars, k)
+		}
+	})
+}
+
+func (m *memoryBackend) Group(datastore, envName, groupName string) (AnsibleGroups, error) {
+	result := AnsibleGroups{}
+	err := m.get(datastore, groupsBucket(envName), groupName, &result)
+	return result, err
+}
+
+func (m *memoryBackend) Groups(datastore, envName string) ([]AnsibleGroups, error) {
+	result := []AnsibleGroups{}
+	err := m.each(datastore, groupsBucket(envName), func(data []byte) ([]byte, error) {
+		group := AnsibleGroups{}
+		if err := json.Unmarshal(data, &group); err != nil {
+			return nil, err
+		}
+		result = append(result, group)
+		return nil, nil
+	})
+	return result, err
+}
+
+func (m *memoryBackend) InsertGroup(datastore string, group AnsibleGroups) error {
+	return m.put(datastore, groupsBucket(group.Environment), group.Name, group, false, true)
+}
+
+func (m *memoryBackend) DeleteGroup(datastore, envName, groupName string) error {
+	return m.remove(datastore, groupsBucket(envName), groupName)
+}
+
+func (m *memoryBackend) AddGroupMember(datastore, envName, groupName, hostName string) error {
+	return m.modifyGroup(datastore, envName, groupName, func(group *AnsibleGroups) {
+		group.Members[groupName] = addToSet(group.Members[groupName], hostName)
+	})
+}
+
+func (m *memoryBackend) RemoveGroupMember(datastore, envName, groupName, hostName string) error {
+	return m.modifyGroup(datastore, envName, groupName, func(group *AnsibleGroups) {
+		group.Members[groupName] = pull(group.Members[groupName], hostName)
+	})
+}
+
+func (m *memoryBackend) AddGroupChild(datastore, envName, groupName, childName string) error {
+	return m.modifyGroup(datastore, envName, groupName, func(group *AnsibleGroups) {
+		group.Children = addToSet(group.Children, childName)
+	})
+}
+
+func (m *memoryBackend) RemoveGroupChild(datastore, envName, groupName, childName string) error {
+	return m.modifyGroup(datastore, envName, groupName, func(group *AnsibleGroups) {
+		group.Children = pull(group.Children, childName)
+	})
+}
+
+func (m *memoryBackend) RemoveChildFromGroups(datastore, envName, childName string) error {
+	return m.each(datastore, groupsBucket(envName), func(data []byte) ([]byte, error) {
+		group := AnsibleGroups{}
+		if err := json.Unmarshal(data, &group); err != nil {
+			return nil, err
+		}
+		children := pull(group.Children, childName)
+		if len(children) == len(group.Children) {
+			return nil, nil
+		}
+		group.Children = children
+		return json.Marshal(group)
+	})
+}
+
+func (m *memoryBackend) SetGroupVars(datastore, envName, groupName string, varMap map[string]string) error {
+	return m.modifyGroup(datastore, envName, groupName, func(group *AnsibleGroups) {
+		for k, v := range varMap {
+			group.Vars[k] = v
+		}
+	})
+}
+
+func (m *memoryBackend) UnsetGroupVars(datastore, envName, groupName string, keys []string) error {
+	return m.modifyGroup(datastore, envName, groupName, func(group *AnsibleGroups) {
+		for _, k := range keys {
+			delete(group.Vars, k)
+		}
+	})
+}
+
+func (m *memoryBackend) Environment(datastore, envName string) (AnsibleEnvironment, error) {
+	result := AnsibleEnvironment{}
+	err := m.get(datastore, "environments", envName, &result)
+	return result, err
+}
+
+func (m *memoryBackend) InsertEnvironment(datastore string, env AnsibleEnvironment) error {
+	return m.put(datastore, "environments", env.Name, env, false, true)
+}
+
+func (m *memoryBackend) SetEnvironmentGroup(datastore, envName, groupName string) error {
+	return m.modifyEnvironment(datastore, envName, func(env *AnsibleEnvironment) {
+		env.Groups[groupName] = true
+	})
+}
+
+func (m *memoryBackend) UnsetEnvironmentGroup(datastore, envName, groupName string) error {
+	return m.modifyEnvironment(datastore, envName, func(env *AnsibleEnvironment) {
+		delete(env.Groups, groupName)
+	})
+}
+
+func (m *memoryBackend) InventoryFile(datastore, envName string) (InventoryFile, error) {
+	result := InventoryFile{}
+	err := m.get(datastore, "inventory_files", envName, &result)
+	return result, err
+}
+
+func (m *memoryBackend) InsertInventoryFile(datastore string, file InventoryFile) error {
+	return m.put(datastore, "inventory_files", file.Environment, file, false, true)
+}
+
+func (m *memoryBackend) PutInventoryFile(datastore string, file InventoryFile) error {
+	return m.put(datastore, "inventory_files", file.Environment, file, false, false)
+}
+
+func (m *memoryBackend) DeleteInventoryFile(datastore, envName string) error {
+	return m.remove(datastore, "inventory_files", envName)
+}
+
+func (m *memoryBackend) InsertMove(op MoveOperation) error {
+	return m.put(PROVISIONER, JOURNALCOLLECTION, op.Id, op, false, true)
+}
+
+func (m *memoryBackend) UpdateMove(op MoveOperation) error {
+	return m.put(PROVISIONER, JOURNALCOLLECTION, op.Id, op, true, false)
+}
+
+func (m *memoryBackend) UnfinishedMoves() ([]MoveOperation, error) {
+	ops := []MoveOperation{}
+	err := m.each(PROVISIONER, JOURNALCOLLECTION, func(data []byte) ([]byte, error) {
+		op := MoveOperation{}
+		if err := json.Unmarshal(data, &op); err != nil {
+			return nil, err
+		}
+		if op.State == MOVECOPYING || op.State == MOVEREMOVING {
+			ops = append(ops, op)
+		}
+		return nil, nil
+	})
+
+	sort.Slice(ops, func(i, j int) bool { return ops[i].Started.Before(ops[j].Started) })
+	return ops, err
+}
+
+func (m *memoryBackend) InsertAudit(record AuditRecord) error {
+	return m.put(PROVISIONER, AUDITCOLLECTION, record.Id, record, false, true)
+}
+
+func (m *memoryBackend) AuditRecords(filter AuditFilter) ([]AuditRecord, error) {
+	records := []AuditRecord{}
+	err := m.each(PROVISIONER, AUDITCOLLECTION, func(data []byte) ([]byte, error) {
+		record := AuditRecord{}
+		if err := json.Unmarshal(data, &record); err != nil {
+			return nil, err
+		}
+		if filter.matches(record) {
+			records = append(records, record)
+		}
+		return nil, nil
+	})
+
+	sort.SliceStable(records, func(i, j int) bool { return records[i].Time.Before(records[j].Time) })
+	return records, err
+}
@@ -0,0 +1,472 @@
+package inventory
+
+import (
+	"context"
+	"fmt"
+	"go.mongodb.org/mongo-driver/v2/bson"
+	"go.mongodb.org/mongo-driver/v2/mongo"
+	"go.mongodb.org/mongo-driver/v2/mongo/options"
+	"strings"
+	"sync"
+)
+
+// mongoBackend keeps every datastore in its own mongo database and the hosts and groups
+// of every environment in their own collections. It owns the single mongo client of a
+// clerk run instead of connecting to mongo for every operation.
+type mongoBackend struct {
+	config *Config
+	client *mongo.Client
+
+	mu      sync.Mutex
+	indexed map[string]bool
+}
+
+// unique key of each kind of collection, keyed by collection name or collection name suffix.
+// The unique indexes turn the existence checks into point lookups and stop duplicate
+// documents from being inserted by concurrent runs.
+var uniqueKeys = map[string]string{
+	"_hosts":          "fqdn",
+	"_groups":         "name",
+	"environments":    "name",
+	"inventory_files": "environment",
+}
+
+// connects to the mongo server described by the configuration
+func dialMongo(mc MongoConfig) (*mongo.Client, error) {
+	opts := options.Client().ApplyURI(mc.URI)
+
+	// explicit settings win over the ones embedded in the URI
+	if mc.Username != "" {
+		credential := options.Credential{Username: mc.Username, Password: mc.Password, PasswordSet: true}
+		if opts.Auth != nil {
+			credential.AuthMechanism = opts.Auth.AuthMechanism
+			credential.AuthSource = opts.Auth.AuthSource
+		}
+		opts.SetAuth(credential)
+	}
+	if mc.AuthSource != "" && opts.Auth != nil {
+		opts.Auth.AuthSource = mc.AuthSource
+	}
+	if mc.ReplicaSet != "" {
+		opts.SetReplicaSet(mc.ReplicaSet)
+	}
+	if mc.ConnectTimeout > 0 {
+		opts.SetConnectTimeout(mc.ConnectTimeout)
+		opts.SetServerSelectionTimeout(mc.ConnectTimeout)
+	}
+
+	if mc.TLS {
+		tlsConfig, err := mc.tlsConfig()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+		}
+		opts.SetTLSConfig(tlsConfig)
+	}
+
+	// keep the documents in the shape earlier releases wrote them: empty arrays and maps instead of null
+	// so $addToSet and $set keep working, and journal ids written as object ids readable
+	opts.SetBSONOptions(&options.BSONOptions{NilSliceAsEmpty: true, NilMapAsEmpty: true, ObjectIDAsHexString: true})
+
+	client, err := mongo.Connect(opts)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidConfig, err)
+	}
+
+	// fail at start-up rather than on the first operation when the server is unreachable
+	ctx, cancel := mongoContext(mc)
+	defer cancel()
+	if err = client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, unavailable(err)
+	}
+
+	return client, nil
+}
+
+// opens the mongo backend by connecting to the mongo server of the configuration once
+func openMongo(cfg *Config) (*mongoBackend, error) {
+	client, err := dialMongo(cfg.Mongo)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mongoBackend{config: cfg, client: client, indexed: make(map[string]bool)}, nil
+}
+
+// Close disconnects the client of the backend
+func (m *mongoBackend) Close() error {
+	ctx, cancel := mongoContext(m.config.Mongo)
+	defer cancel()
+	return m.client.Disconnect(ctx)
+}
+
+// returns the context of a single mongo call, bounded by the configured timeout
+func mongoContext(mc MongoConfig) (context.Context, context.CancelFunc) {
+	if mc.Timeout > 0 {
+		return context.WithTimeout(context.Background(), mc.Timeout)
+	}
+	return context.WithCancel(context.Background())
+}
+
+// returns the mongo database that backs a datastore
+func (m *mongoBackend) db(datastore string) *mongo.Database {
+	return m.client.Database(m.config.DatabaseName(datastore))
+}
+
+// returns a collection of a datastore
+func (m *mongoBackend) collection(datastore, name string) *mongo.Collection {
+	return m.db(datastore).Collection(name)
+}
+
+// ensures the unique index of its kind exists on a collection before documents are added to it,
+// once per run. The lookups work without the index, so reads do not wait for it.
+func (m *mongoBackend) ensureIndex(c *mongo.Collection) error {
+	fullName := c.Database().Name() + "." + c.Name()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.indexed[fullName] {
+		return nil
+	}
+
+	for suffix, key := range uniqueKeys {
+		if !strings.HasSuffix(c.Name(), suffix) {
+			continue
+		}
+
+		ctx, cancel := mongoContext(m.config.Mongo)
+		_, err := c.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.D{{Key: key, Value: 1}}, Options: options.Index().SetUnique(true)})
+		cancel()
+		if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+			err = unavailable(err)
+		}
+		if err != nil {
+			// most likely the collection holds duplicates that need cleaning up first
+			return fmt.Errorf("creating the unique %s index on %s: %w", key, fullName, err)
+		}
+	}
+
+	m.indexed[fullName] = true
+	return nil
+}
+
+// returns the hosts collection of an environment
+func (m *mongoBackend) hosts(datastore, envName string) *mongo.Collection {
+	return m.collection(datastore, EnvPrefix(envName)+"_hosts")
+}
+
+// returns the groups collection of an environment
+func (m *mongoBackend) groups(datastore, envName string) *mongo.Collection {
+	return m.collection(datastore, EnvPrefix(envName)+"_groups")
+}
+
+// translates the errors of the driver into the errors of the Backend interface
+func mongoError(err error) error {
+	if err == mongo.ErrNoDocuments {
+		return ErrNotFound
+	}
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrAlreadyExists
+	}
+	if mongo.IsNetworkError(err) || mongo.IsTimeout(err) {
+		return unavailable(err)
+	}
+	return err
+}
+
+// decodes the single document matching filter into result
+func (m *mongoBackend) findOne(c *mongo.Collection, filter interface{}, result interface{}) error {
+	ctx, cancel := mongoContext(m.config.Mongo)
+	defer cancel()
+	return mongoError(c.FindOne(ctx, filter).Decode(result))
+}
+
+// decodes every document matching filter into result, a pointer to a slice
+func (m *mongoBackend) findAll(c *mongo.Collection, filter interface{}, result interface{}, opts ...options.Lister[options.FindOptions]) error {
+	ctx, cancel := mongoContext(m.config.Mongo)
+	defer cancel()
+
+	cursor, err := c.Find(ctx, filter, opts...)
+	if err != nil {
+		return mongoError(err)
+	}
+	return mongoError(cursor.All(ctx, result))
+}
+
+func (m *mongoBackend) insertOne(c *mongo.Collection, document interface{}) error {
+	if err := m.ensureIndex(c); err != nil {
+		return err
+	}
+
+	ctx, cancel := mongoContext(m.config.Mongo)
+	defer cancel()
+	_, err := c.InsertOne(ctx, document)
+	return mongoError(err)
+}
+
+// applies update to the single document matching filter, reporting ErrNotFound when there is none
+func (m *mongoBackend) updateOne(c *mongo.Collection, filter, update interface{}) error {
+	ctx, cancel := mongoContext(m.config.Mongo)
+	defer cancel()
+
+	result, err := c.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.MatchedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoBackend) updateMany(c *mongo.Collection, filter, update interface{}) error {
+	ctx, cancel := mongoContext(m.config.Mongo)
+	defer cancel()
+	_, err := c.UpdateMany(ctx, filter, update)
+	return mongoError(err)
+}
+
+// replaces the single document matching filter, inserting it when there is none
+func (m *mongoBackend) upsertOne(c *mongo.Collection, filter, document interface{}) error {
+	if err := m.ensureIndex(c); err != nil {
+		return err
+	}
+
+	ctx, cancel := mongoContext(m.config.Mongo)
+	defer cancel()
+	_, err := c.ReplaceOne(ctx, filter, document, options.Replace().SetUpsert(true))
+	return mongoError(err)
+}
+
+// removes the single document matching filter, reporting ErrNotFound when there is none
+func (m *mongoBackend) deleteOne(c *mongo.Collection, filter interface{}) error {
+	ctx, cancel := mongoContext(m.config.Mongo)
+	defer cancel()
+
+	result, err := c.DeleteOne(ctx, filter)
+	if err != nil {
+		return mongoError(err)
+	}
+	if result.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (m *mongoBackend) Host(datastore, envName, hostName string) (AnsibleHost, error) {
+	result := AnsibleHost{}
+	err := m.findOne(m.hosts(datastore, envName), bson.M{"fqdn": hostName}, &result)
+	return result, err
+}
+
+func (m *mongoBackend) Hosts(datastore, envName string, filter HostFilter) ([]AnsibleHost, error) {
+	query := bson.M{}
+	if filter.OsType != "" {
+		query["ostype"] = filter.OsType
+	}
+	if filter.OsVersion != "" {
+		query["osversion"] = filter.OsVersion
+	}
+	if filter.ArchType != "" {
+		query["archtype"] = filter.ArchType
+	}
+
+	result := []AnsibleHost{}
+	err := m.findAll(m.hosts(datastore, envName), query, &result)
+	return result, err
+}
+
+func (m *mongoBackend) InsertHost(datastore string, host AnsibleHost) error {
+	return m.insertOne(m.hosts(datastore, host.Environment), &host)
+}
+
+func (m *mongoBackend) PutHost(datastore string, host AnsibleHost) error {
+	return m.upsertOne(m.hosts(datastore, host.Environment), bson.M{"fqdn": host.Fqdn}, &host)
+}
+
+func (m *mongoBackend) DeleteHost(datastore, envName, hostName string) error {
+	return m.deleteOne(m.hosts(datastore, envName), bson.M{"fqdn": hostName})
+}
+
+func (m *mongoBackend) SetHostGroup(datastore, envName, hostName, groupName string) error {
+	// flag the group in the hosts groups map
+	return m.updateOne(m.hosts(datastore, envName), bson.M{"fqdn": hostName}, bson.M{"$set": bson.M{"groups." + groupName: true}})
+}
+
+func (m *mongoBackend) UnsetHostGroup(datastore, envName, hostName, groupName string) error {
+	return m.updateOne(m.hosts(datastore, envName), bson.M{"fqdn": hostName}, bson.M{"$unset": bson.M{"groups." + groupName: ""}})
+}
+
+func (m *mongoBackend) UnsetGroupFromHosts(datastore, envName, groupName string) error {
+	// detach the group from every host that references it in a single update
+	return m.updateMany(m.hosts(datastore, envName), bson.M{"groups." + groupName: bson.M{"$exists": true}}, bson.M{"$unset": bson.M{"groups." + groupName: ""}})
+}
+
+func (m *mongoBackend) SetHostVars(datastore, envName, hostName string, varMap map[string]string) error {
+	return m.updateOne(m.hosts(datastore, envName), bson.M{"fqdn": hostName}, bson.M{"$set": varsUpdate(varMap)})
+}
+
+func (m *mongoBackend) UnsetHostVars(datastore, envName, hostName string, keys []string) error {
+	return m.updateOne(m.hosts(datastore, envName), bson.M{"fqdn": hostName}, bson.M{"$unset": varsRemoval(keys)})
+}
+
+func (m *mongoBackend) Group(datastore, envName, groupName string) (AnsibleGroups, error) {
+	result := AnsibleGroups{}
+	err := m.findOne(m.groups(datastore, envName), bson.M{"name": groupName}, &result)
+	return result, err
+}
+
+func (m *mongoBackend) Groups(datastore, envName string) ([]AnsibleGroups, error) {
+	result := []AnsibleGroups{}
+	err := m.findAll(m.groups(datastore, envName), bson.M{}, &result)
+	return result, err
+}
+
+func (m *mongoBackend) InsertGroup(datastore string, group AnsibleGroups) error {
+	return m.insertOne(m.groups(datastore, group.Environment), &group)
+}
+
+func (m *mongoBackend) DeleteGroup(datastore, envName, groupName string) error {
+	return m.deleteOne(m.groups(datastore, envName), bson.M{"name": groupName})
+}
+
+func (m *mongoBackend) AddGroupMember(datastore, envName, groupName, hostName string) error {
+	// add the host to the group members slice unless it is already there
+	return m.updateOne(m.groups(datastore, envName), bson.M{"name": groupName}, bson.M{"$addToSet": bson.M{"members." + groupName: hostName}})
+}
+
+func (m *mongoBackend) RemoveGroupMember(datastore, envName, groupName, hostName string) error {
+	return m.updateOne(m.groups(datastore, envName), bson.M{"name": groupName}, bson.M{"$pull": bson.M{"members." + groupName: hostName}})
+}
+
+func (m *mongoBackend) AddGroupChild(datastore, envName, groupName, childName string) error {
+	return m.updateOne(m.groups(datastore, envName), bson.M{"name": groupName}, bson.M{"$addToSet": bson.M{"children": childName}})
+}
+
+func (m *mongoBackend) RemoveGroupChild(datastore, envName, groupName, childName string) error {
+	return m.updateOne(m.groups(datastore, envName), bson.M{"name": groupName}, bson.M{"$pull": bson.M{"children": childName}})
+}
+
+func (m *mongoBackend) RemoveChildFromGroups(datastore, envName, childName string) error {
+	return m.updateMany(m.groups(datastore, envName), bson.M{"children": childName}, bson.M{"$pull": bson.M{"children": childName}})
+}
+
+func (m *mongoBackend) SetGroupVars(datastore, envName, groupName string, varMap map[string]string) error {
+	return m.updateOne(m.groups(datastore, envName), bson.M{"name": groupName}, bson.M{"$set": varsUpdate(varMap)})
+}
+
+func (m *mongoBackend) UnsetGroupVars(datastore, envName, groupName string, keys []string) error {
+	return m.updateOne(m.groups(datastore, envName), bson.M{"name": groupName}, bson.M{"$unset": varsRemoval(keys)})
+}
+
+func (m *mongoBackend) Environment(datastore, envName string) (AnsibleEnvironment, error) {
+	result := AnsibleEnvironment{}
+	err := m.findOne(m.collection(datastore, "environments"), bson.M{"name": envName}, &result)
+	return result, err
+}
+
+func (m *mongoBackend) InsertEnvironment(datastore string, env AnsibleEnvironment) error {
+	return m.insertOne(m.collection(datastore, "environments"), &env)
+}
+
+func (m *mongoBackend) SetEnvironmentGroup(datastore, envName, groupName string) error {
+	return m.updateOne(m.collection(datastore, "environments"), bson.M{"name": envName}, bson.M{"$set": bson.M{"groups." + groupName: true}})
+}
+
+func (m *mongoBackend) UnsetEnvironmentGroup(datastore, envName, groupName string) error {
+	return m.updateOne(m.collection(datastore, "environments"), bson.M{"name": envName}, bson.M{"$unset": bson.M{"groups." + groupName: ""}})
+}
+
+func (m *mongoBackend) InventoryFile(datastore, envName string) (InventoryFile, error) {
+	result := InventoryFile{}
+	err := m.findOne(m.collection(datastore, "inventory_files"), bson.M{"environment": envName}, &result)
+	return result, err
+}
+
+func (m *mongoBackend) InsertInventoryFile(datastore string, file InventoryFile) error {
+	return m.insertOne(m.collection(datastore, "inventory_files"), &file)
+}
+
+func (m *mongoBackend) PutInventoryFile(datastore string, file InventoryFile) error {
+	return m.upsertOne(m.collection(datastore, "inventory_files"), bson.M{"environment": file.Environment}, &file)
+}
+
+func (m *mongoBackend) DeleteInventoryFile(datastore, envName string) error {
+	return m.deleteOne(m.collection(datastore, "inventory_files"), bson.M{"environment": envName})
+}
+
+func (m *mongoBackend) InsertMove(op MoveOperation) error {
+	return m.insertOne(m.collection(PROVISIONER, JOURNALCOLLECTION), &op)
+}
+
+func (m *mongoBackend) UpdateMove(op MoveOperation) error {
+	// only the progress of a move changes once it is journaled
+	update := bson.M{"$set": bson.M{"state": op.State, "createdgroups": op.CreatedGroups, "updated": op.Updated}}
+	return m.updateOne(m.collection(PROVISIONER, JOURNALCOLLECTION), moveFilter(op.Id), update)
+}
+
+func (m *mongoBackend) UnfinishedMoves() ([]MoveOperation, error) {
+	ops := []MoveOperation{}
+	err := m.findAll(m.collection(PROVISIONER, JOURNALCOLLECTION), bson.M{"state": bson.M{"$in": []string{MOVECOPYING, MOVEREMOVING}}}, &ops,
+		options.Find().SetSort(bson.D{{Key: "started", Value: 1}}))
+	return ops, err
+}
+
+func (m *mongoBackend) InsertAudit(record AuditRecord) error {
+	return m.insertOne(m.collection(PROVISIONER, AUDITCOLLECTION), &record)
+}
+
+func (m *mongoBackend) AuditRecords(filter AuditFilter) ([]AuditRecord, error) {
+	query := bson.M{}
+	if filter.Environment != "" {
+		query["environment"] = filter.Environment
+	}
+	if filter.Host != "" {
+		query["hosts"] = filter.Host
+	}
+	if filter.Group != "" {
+		query["groups"] = filter.Group
+	}
+	if filter.User != "" {
+		query["user"] = filter.User
+	}
+	period := bson.M{}
+	if !filter.Since.IsZero() {
+		period["$gte"] = filter.Since
+	}
+	if !filter.Until.IsZero() {
+		period["$lte"] = filter.Until
+	}
+	if len(period) > 0 {
+		query["time"] = period
+	}
+
+	records := []AuditRecord{}
+	err := m.findAll(m.collection(PROVISIONER, AUDITCOLLECTION), query, &records, options.Find().SetSort(bson.D{{Key: "time", Value: 1}}))
+	return records, err
+}
+
+// matches the journal entry of a move, entries journaled by older clerk releases have object ids
+func moveFilter(id string) bson.M {
+	if oid, err := bson.ObjectIDFromHex(id); err == nil {
+		return bson.M{"_id": bson.M{"$in": []interface{}{id, oid}}}
+	}
+	return bson.M{"_id": id}
+}
+
+// sets each variable individually so that existing variables are left untouched
+func varsUpdate(varMap map[string]string) bson.M {
+	update := bson.M{}
+	for k, v := range varMap {
+		update["vars."+k] = v
+	}
+	return update
+}
+
+func varsRemoval(keys []string) bson.M {
+	update := bson.M{}
+	for _, k := range keys {
+		update["vars."+k] = ""
+	}
+	return update
+}
@@ -0,0 +1,70 @@
+// Package inventory keeps the Ansible inventory of capernicus: the hosts, groups and
+// environments of the provisioner and custodian datastores and the inventory files
+// generated from them. Every operation returns an error instead of exiting, so the
+// package can be used by other tools as well as by the clerk command.
+package inventory
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Store runs the inventory operations of a clerk run against a storage backend
+type Store struct {
+	config  *Config
+	backend Backend
+
+	// what a dry run store copied and would have written, nil for a store that writes
+	plan *dryRunPlan
+
+	// who the audit records name, see SetActor
+	actor AuditActor
+
+	// handed the errors writing audit records, see SetAuditWarning
+	auditWarning func(err error)
+}
+
+// Open opens the store on the backend selected by the configuration
+func Open(cfg *Config) (*Store, error) {
+	var backend Backend
+	var err error
+
+	switch cfg.Backend {
+	case MONGOBACKEND:
+		backend, err = openMongo(cfg)
+	case BOLTBACKEND:
+		backend, err = openBolt(cfg)
+	default:
+		err = fmt.Errorf("%w: unknown backend %q", ErrInvalidConfig, cfg.Backend)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return NewStore(cfg, backend), nil
+}
+
+// NewStore returns a store on a backend that was opened by the caller
+func NewStore(cfg *Config, backend Backend) *Store {
+	return &Store{config: cfg, backend: backend, actor: currentActor()}
+}
+
+// Close releases the backend of the store
+func (s *Store) Close() error {
+	return s.backend.Close()
+}
+
+// Config returns the configuration the store was opened with
+func (s *Store) Config() *Config {
+	return s.config
+}
+
+// EnvPrefix returns the prefix of the collections, directories and default group of an environment
+func EnvPrefix(envName string) string {
+	return strings.ToLower(strings.Replace(envName, "-", "_", -1))
+}
+
+// AllGroup returns the name of the default group every host of an environment belongs to
+func AllGroup(envName string) string {
+	return EnvPrefix(envName) + "_all"
+}
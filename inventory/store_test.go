@@ -0,0 +1,142 @@
+package inventory
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+const testEnv string = "dev-east"
+
+// opens an empty backend for a test
+type openBackend func(t *testing.T) Backend
+
+// the backends the store tests run against
+var testBackends = []struct {
+	name string
+	open openBackend
+}{
+	{name: "memory", open: func(t *testing.T) Backend { return NewMemoryBackend() }},
+	{name: "bolt", open: openTestBolt},
+}
+
+// opens the bolt backend on a file in a temporary directory, closed when the test ends
+func openTestBolt(t *testing.T) Backend {
+	t.Helper()
+	backend, err := openBolt(&Config{Bolt: BoltConfig{Path: filepath.Join(t.TempDir(), "clerk.db")}})
+	if err != nil {
+		t.Fatalf("opening bolt backend: %v", err)
+	}
+	t.Cleanup(func() { backend.Close() })
+	return backend
+}
+
+// runs a test against every backend
+func forEachBackend(t *testing.T, test func(t *testing.T, open openBackend)) {
+	for _, backend := range testBackends {
+		t.Run(backend.name, func(t *testing.T) { test(t, backend.open) })
+	}
+}
+
+// returns a store on an empty in-memory backend with the test environment in both datastores
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	return newTestStoreOn(t, NewMemoryBackend())
+}
+
+// returns a store on an empty backend with the test environment in both datastores
+func newTestStoreOn(t *testing.T, backend Backend) *Store {
+	t.Helper()
+
+	cfg := &Config{Datastores: make(map[string]DatastoreConfig)}
+	cfg.applyDefaults()
+	for name := range cfg.Datastores {
+		cfg.Datastores[name] = DatastoreConfig{Database: name, InventoryRoot: t.TempDir() + "/"}
+	}
+
+	s := NewStore(cfg, backend)
+	for _, ds := range []string{PROVISIONER, CUSTODIAN} {
+		if err := s.AddEnvironment(&AnsibleEnvironment{Name: testEnv}, ds); err != nil {
+			t.Fatalf("adding environment %s to %s: %v", testEnv, ds, err)
+		}
+	}
+	return s
+}
+
+// adds a host to the test environment of a datastore
+func mustAddHost(t *testing.T, s *Store, fqdn string, datastore string) {
+	t.Helper()
+	host := AnsibleHost{Fqdn: fqdn, Environment: testEnv, OsType: "linux", OsVersion: "8", ArchType: "x86_64"}
+	if err := s.AddHost(host, datastore); err != nil {
+		t.Fatalf("adding host %s: %v", fqdn, err)
+	}
+}
+
+func mustAddGroup(t *testing.T, s *Store, name string, datastore string) {
+	t.Helper()
+	group := AnsibleGroups{Name: name, Environment: testEnv, Description: name + " group"}
+	if err := s.AddGroup(group, datastore); err != nil {
+		t.Fatalf("adding group %s: %v", name, err)
+	}
+}
+
+func mustAttach(t *testing.T, s *Store, host, group, datastore string) {
+	t.Helper()
+	if err := s.AttachHost(host, group, testEnv, datastore); err != nil {
+		t.Fatalf("attaching host %s to group %s: %v", host, group, err)
+	}
+}
+
+// returns the members of a group, sorted
+func members(t *testing.T, s *Store, group, datastore string) []string {
+	t.Helper()
+	result, err := s.Group(group, testEnv, datastore)
+	if err != nil {
+		t.Fatalf("reading group %s: %v", group, err)
+	}
+	names := append([]string{}, result.Members[group]...)
+	sort.Strings(names)
+	return names
+}
+
+// returns the groups a host belongs to, sorted
+func hostGroups(t *testing.T, s *Store, host, datastore string) []string {
+	t.Helper()
+	result, err := s.Host(host, testEnv, datastore)
+	if err != nil {
+		t.Fatalf("reading host %s: %v", host, err)
+	}
+	names := []string{}
+	for name := range result.Groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func checkErr(t *testing.T, err, want error) {
+	t.Helper()
+	if want == nil && err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want != nil && !errors.Is(err, want) {
+		t.Fatalf("got error %v, want %v", err, want)
+	}
+}
+
+// compares two sets of names kept as slices, the order does not matter
+func checkStrings(t *testing.T, what string, got, want []string) {
+	t.Helper()
+	if len(got) == 0 && len(want) == 0 {
+		return
+	}
+	got = append([]string{}, got...)
+	want = append([]string{}, want...)
+	sort.Strings(got)
+	sort.Strings(want)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
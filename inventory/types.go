@@ -0,0 +1,62 @@
+package inventory
+
+// Type Definitions
+
+// AnsibleGroups is a group of an environment, Members holds the hosts of the group under its name
+type AnsibleGroups struct {
+	Members     map[string][]string
+	Description string
+	Environment string
+	Name        string
+	Vars        map[string]string
+	Children    []string
+}
+
+// AnsibleInventoryGroup is a group as ansible expects it from a dynamic inventory
+type AnsibleInventoryGroup struct {
+	Hosts    []string          `json:"hosts"`
+	Vars     map[string]string `json:"vars,omitempty"`
+	Children []string          `json:"children,omitempty"`
+}
+
+// AnsibleHostMeta carries the variables of every host so ansible does not need to ask per host
+type AnsibleHostMeta struct {
+	HostVars map[string]map[string]string `json:"hostvars"`
+}
+
+// AnsibleHost is a host of an environment, Groups flags the groups the host belongs to
+type AnsibleHost struct {
+	Fqdn        string
+	Groups      map[string]bool
+	Environment string
+	OsType      string
+	OsVersion   string
+	ArchType    string
+	Vars        map[string]string
+}
+
+// AnsibleEnvironment is an environment, Groups flags the groups that belong to it
+type AnsibleEnvironment struct {
+	Name   string
+	Prefix string
+	Groups map[string]bool
+}
+
+// AnsibleHostVars holds the variables handed to ansible for a host
+type AnsibleHostVars struct {
+	Name   string
+	VarMap map[string]string
+}
+
+// InventoryFile records where the inventory file of an environment is written
+type InventoryFile struct {
+	Path        string
+	Environment string
+}
+
+// HostFilter narrows down a host listing by host facts, empty facts match any host
+type HostFilter struct {
+	OsType    string
+	OsVersion string
+	ArchType  string
+}
@@ -0,0 +1,122 @@
+package inventory
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// HostVarMap builds the variable map that is handed to ansible for a host from its facts and variables
+func HostVarMap(aHost AnsibleHost) map[string]string {
+	varMap := make(map[string]string)
+
+	// expose the stored host facts, explicitly set variables take precedence
+	if aHost.OsType != "" {
+		varMap["os_type"] = aHost.OsType
+	}
+	if aHost.OsVersion != "" {
+		varMap["os_version"] = aHost.OsVersion
+	}
+	if aHost.ArchType != "" {
+		varMap["arch_type"] = aHost.ArchType
+	}
+
+	for k, v := range aHost.Vars {
+		varMap[k] = v
+	}
+	return varMap
+}
+
+// ParseVars parses a comma delimited list of key=value pairs into a variable map. Empty items,
+// such as the one after a trailing comma, are skipped, but the list must hold a pair.
+func ParseVars(varList string) (map[string]string, error) {
+	varMap := make(map[string]string)
+	for _, pair := range strings.Split(varList, ",") {
+		if strings.TrimSpace(pair) == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 {
+			return nil, &Error{Kind: "variable", Name: strconv.Quote(pair), Err: ErrMalformed}
+		}
+
+		k := strings.TrimSpace(kv[0])
+		if !ValidVarName(k) {
+			return nil, &Error{Kind: "variable name", Name: strconv.Quote(k), Err: ErrInvalidName}
+		}
+		varMap[k] = strings.TrimSpace(kv[1])
+	}
+	if len(varMap) == 0 {
+		return nil, &Error{Kind: "variable list", Name: strconv.Quote(varList), Err: ErrMalformed}
+	}
+	return varMap, nil
+}
+
+// ValidVarName reports whether name is a valid ansible variable name: letters, numbers and
+// underscores that do not start with a number
+func ValidVarName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// ValidGroupName reports whether name may be used for a group, group names become field
+// names in the hosts groups map and the group members map
+func ValidGroupName(name string) bool {
+	return name != "" && !strings.Contains(name, ".") && !strings.HasPrefix(name, "$")
+}
+
+// ValidInventoryName reports whether name may be written to an inventory file as a group name:
+// letters, numbers and underscores, the characters ansible accepts. Anything else, such as a
+// colon or brackets, would be read back as a different section of an INI file.
+func ValidInventoryName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r == '_', r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// checks every variable name before it is used in a field path
+func checkVarNames(keys []string) error {
+	for _, k := range keys {
+		if !ValidVarName(k) {
+			return &Error{Kind: "variable name", Name: k, Err: ErrInvalidName}
+		}
+	}
+	return nil
+}
+
+// returns the names of the variables of a variable map, sorted
+func varNames(varMap map[string]string) []string {
+	names := make([]string, 0, len(varMap))
+	for k := range varMap {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// trims the variable names given on the command line
+func trimVarNames(keys []string) []string {
+	names := make([]string, len(keys))
+	for i, k := range keys {
+		names[i] = strings.TrimSpace(k)
+	}
+	return names
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/raiderops/capernicus/inventory"
+	"gopkg.in/yaml.v2"
+	"os"
+	"sort"
+	"time"
+)
+
+// Output formats of the read commands, see the -output flag
+const (
+	TABLEOUTPUT string = "table"
+	JSONOUTPUT  string = "json"
+	YAMLOUTPUT  string = "yaml"
+)
+
+// hostOutput is a host as the read commands print it in json and yaml. Its fields are a stable
+// schema for scripts: fields may be added but are never renamed or removed.
+type hostOutput struct {
+	Name        string            `json:"name" yaml:"name"`
+	Environment string            `json:"environment" yaml:"environment"`
+	Datastore   string            `json:"datastore" yaml:"datastore"`
+	OsType      string            `json:"os_type" yaml:"os_type"`
+	OsVersion   string            `json:"os_version" yaml:"os_version"`
+	ArchType    string            `json:"arch_type" yaml:"arch_type"`
+	Groups      []string          `json:"groups" yaml:"groups"`
+	Vars        map[string]string `json:"vars" yaml:"vars"`
+}
+
+// groupOutput is a group as the read commands print it in json and yaml, with the same
+// stability promise as hostOutput
+type groupOutput struct {
+	Name        string            `json:"name" yaml:"name"`
+	Environment string            `json:"environment" yaml:"environment"`
+	Datastore   string            `json:"datastore" yaml:"datastore"`
+	Description string            `json:"description" yaml:"description"`
+	Members     []string          `json:"members" yaml:"members"`
+	Children    []string          `json:"children" yaml:"children"`
+	Vars        map[string]string `json:"vars" yaml:"vars"`
+}
+
+// backupOutput is a backup of an inventory file as clerk inventory backups prints it in json
+// and yaml, with the same stability promise as hostOutput
+type backupOutput struct {
+	Timestamp string    `json:"timestamp" yaml:"timestamp"`
+	Time      time.Time `json:"time" yaml:"time"`
+	Path      string    `json:"path" yaml:"path"`
+}
+
+// inventoryDiffOutput is the comparison of an inventory file with the datastore as clerk
+// inventory diff prints it in json and yaml, with the same stability promise as hostOutput
+type inventoryDiffOutput struct {
+	From     string                 `json:"from" yaml:"from"`
+	To       string                 `json:"to" yaml:"to"`
+	UpToDate bool                   `json:"up_to_date" yaml:"up_to_date"`
+	Diff     string                 `json:"diff" yaml:"diff"`
+	Changes  inventoryChangesOutput `json:"changes" yaml:"changes"`
+}
+
+type inventoryChangesOutput struct {
+	AddedGroups    []string            `json:"added_groups" yaml:"added_groups"`
+	RemovedGroups  []string            `json:"removed_groups" yaml:"removed_groups"`
+	ChangedGroups  []string            `json:"changed_groups" yaml:"changed_groups"`
+	AddedHosts     []string            `json:"added_hosts" yaml:"added_hosts"`
+	RemovedHosts   []string            `json:"removed_hosts" yaml:"removed_hosts"`
+	AddedMembers   map[string][]string `json:"added_members" yaml:"added_members"`
+	RemovedMembers map[string][]string `json:"removed_members" yaml:"removed_members"`
+}
+
+func newInventoryDiffOutput(diff inventory.InventoryDiff) inventoryDiffOutput {
+	changes := diff.Changes
+	result := inventoryDiffOutput{
+		From:     diff.From,
+		To:       diff.To,
+		UpToDate: changes.Empty() && diff.Unified == "",
+		Diff:     diff.Unified,
+		Changes: inventoryChangesOutput{
+			AddedGroups:    nonNilNames(changes.AddedGroups),
+			RemovedGroups:  nonNilNames(changes.RemovedGroups),
+			ChangedGroups:  nonNilNames(changes.ChangedGroups),
+			AddedHosts:     nonNilNames(changes.AddedHosts),
+			RemovedHosts:   nonNilNames(changes.RemovedHosts),
+			AddedMembers:   changes.AddedMembers,
+			RemovedMembers: changes.RemovedMembers,
+		},
+	}
+	if result.Changes.AddedMembers == nil {
+		result.Changes.AddedMembers = make(map[string][]string)
+	}
+	if result.Changes.RemovedMembers == nil {
+		result.Changes.RemovedMembers = make(map[string][]string)
+	}
+	return result
+}
+
+func newHostOutput(host inventory.AnsibleHost, database string) hostOutput {
+	groupNames := make([]string, 0, len(host.Groups))
+	for name := range host.Groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+
+	return hostOutput{
+		Name:        host.Fqdn,
+		Environment: host.Environment,
+		Datastore:   database,
+		OsType:      host.OsType,
+		OsVersion:   host.OsVersion,
+		ArchType:    host.ArchType,
+		Groups:      groupNames,
+		Vars:        nonNilVars(host.Vars),
+	}
+}
+
+func newGroupOutput(group inventory.AnsibleGroups, database string) groupOutput {
+	memberNames := append([]string{}, group.Members[group.Name]...)
+	sort.Strings(memberNames)
+	childNames := append([]string{}, group.Children...)
+	sort.Strings(childNames)
+
+	return groupOutput{
+		Name:        group.Name,
+		Environment: group.Environment,
+		Datastore:   database,
+		Description: group.Description,
+		Members:     memberNames,
+		Children:    childNames,
+		Vars:        nonNilVars(group.Vars),
+	}
+}
+
+// returns the variables of a host or group, an empty map for none so that scripts always
+// get an object
+func nonNilVars(vars map[string]string) map[string]string {
+	if vars == nil {
+		return map[string]string{}
+	}
+	return vars
+}
+
+// reports whether a -output value names a known format, the empty value is the table
+func validOutput(format string) bool {
+	switch format {
+	case "", TABLEOUTPUT, JSONOUTPUT, YAMLOUTPUT:
+		return true
+	}
+	return false
+}
+
+// prints a value in the json or yaml output format
+func printOutput(format string, v interface{}) {
+	var b []byte
+	var err error
+	if format == YAMLOUTPUT {
+		b, err = yaml.Marshal(v)
+	} else {
+		b, err = json.MarshalIndent(v, "", "   ")
+		b = append(b, '\n')
+	}
+	if err != nil {
+		exit(EXITFAILURE, "ERROR", "Failed to format the output: "+err.Error()+".")
+	}
+
+	os.Stdout.Write(b)
+}
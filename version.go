@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+)
+
+// Build information, set at link time by the Makefile:
+//
+//	go build -ldflags "-X main.version=1.2.0 -X main.commit=3f2c1ab"
+var version = "dev"
+var commit = ""
+
+// returns the commit clerk was built from, falling back to the revision the go tool
+// records when the binary is built from a git checkout without the Makefile
+func buildCommit() string {
+	if commit != "" {
+		return commit
+	}
+
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown"
+	}
+
+	revision, modified := "unknown", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if modified {
+		revision += "-dirty"
+	}
+	return revision
+}
+
+// prints the build version and commit of this clerk binary
+func printVersion() {
+	fmt.Println("clerk " + version + " (commit " + buildCommit() + ", " + runtime.Version() + ")")
+}